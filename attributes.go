@@ -0,0 +1,149 @@
+package gollab
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Attributes are formatting attributes, such as bold or link, carried by Retain and Insert operations.
+//
+// Attributes follow the semantics of Quill deltas. Attributes of an Insert operation are applied to the inserted tokens.
+// Attributes of a Retain operation are applied to the retained tokens, with a nil value removing the attribute.
+//
+// Attribute values have to be comparable using reflect.DeepEqual and encodable to JSON.
+//
+// As Attributes is a map, Retain and Insert values cannot be compared using ==, and comparing PrimitiveOps holding them
+// panics at runtime. Use reflect.DeepEqual to compare operations, or Attributes.Equal to compare their Attributes.
+type Attributes map[string]interface{}
+
+// AttributedToken is implemented by tokens carrying attributes. It is used to format tokens when applying operations
+// with attributes, and to find out the attributes of a document's tokens when inverting such operations.
+type AttributedToken interface {
+	// TokenAttributes returns the attributes of the token.
+	TokenAttributes() Attributes
+	// Format returns a copy of the token with the given attributes composed onto its own, removing the attributes
+	// whose value is nil.
+	Format(attributes Attributes) interface{}
+}
+
+// Equal reports whether two sets of attributes are equal. A nil set equals an empty one.
+func (a Attributes) Equal(b Attributes) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// String provides a string representation of the attributes with sorted keys.
+func (a Attributes) String() string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s:%v", k, a[k])
+	}
+	return "{" + strings.Join(pairs, " ") + "}"
+}
+
+// ComposeAttributes returns the attributes resulting from applying b after a. Attributes set in b override those in a.
+// If keepNil is false, attributes whose value in b is nil are removed from the result, otherwise they are kept in order
+// to remove the attribute from the document later on. It returns nil if the result is empty.
+func ComposeAttributes(a, b Attributes, keepNil bool) Attributes {
+	res := Attributes{}
+	for k, v := range b {
+		if v != nil || keepNil {
+			res[k] = v
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			res[k] = v
+		}
+	}
+
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// TransformAttributes transforms the attributes b against the concurrently applied attributes a. If aWins is true, the
+// attributes set by a take precedence and are removed from b, otherwise b is returned unchanged. It returns nil if the
+// result is empty.
+func TransformAttributes(a, b Attributes, aWins bool) Attributes {
+	if len(b) == 0 {
+		return nil
+	}
+	if !aWins {
+		return b
+	}
+
+	res := Attributes{}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			res[k] = v
+		}
+	}
+
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// InvertAttributes returns the attributes undoing the application of attributes to tokens formatted with base. It
+// returns nil if the result is empty.
+func InvertAttributes(attributes, base Attributes) Attributes {
+	res := Attributes{}
+	for k, v := range attributes {
+		if baseValue, ok := base[k]; ok {
+			if !reflect.DeepEqual(v, baseValue) {
+				res[k] = baseValue
+			}
+		} else if v != nil {
+			res[k] = nil
+		}
+	}
+
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// tokenAttributes returns the attributes of a token, or nil if it isn't an AttributedToken.
+func tokenAttributes(token interface{}) Attributes {
+	if t, ok := token.(AttributedToken); ok {
+		return t.TokenAttributes()
+	}
+	return nil
+}
+
+// formatToken formats a token with the given attributes if it is an AttributedToken. Other tokens are returned as they
+// are, so the attributes of operations applied to plain tokens are ignored.
+func formatToken(token interface{}, attributes Attributes) interface{} {
+	if len(attributes) == 0 {
+		return token
+	}
+	if t, ok := token.(AttributedToken); ok {
+		return t.Format(attributes)
+	}
+	return token
+}
+
+// attributesOf returns the attributes of a Retain or Insert operation, or nil for other operations.
+func attributesOf(p PrimitiveOp) Attributes {
+	switch p := p.(type) {
+	case Retain:
+		return p.Attributes
+	case Insert:
+		return p.Attributes
+	}
+	return nil
+}
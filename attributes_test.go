@@ -0,0 +1,210 @@
+package gollab_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/richtoken"
+	"github.com/danielslee/gollab/runetoken"
+)
+
+var _ gollab.AttributedToken = richtoken.Char{}
+
+func randomAttributes(allowNil bool) gollab.Attributes {
+	choices := []gollab.Attributes{
+		nil,
+		{"bold": true},
+		{"italic": true},
+		{"color": "red"},
+		{"color": "blue", "bold": true},
+	}
+	if allowNil {
+		choices = append(choices, gollab.Attributes{"bold": nil}, gollab.Attributes{"color": nil, "italic": true})
+	}
+	return choices[rand.Intn(len(choices))]
+}
+
+func randomRichOp(inLength int) gollab.CompositeOp {
+	var ops []gollab.PrimitiveOp
+	for remaining := inLength; remaining > 0; {
+		count := rand.Intn(remaining) + 1
+		switch rand.Intn(3) {
+		case 0:
+			ops = append(ops, gollab.Retain{Count: count, Attributes: randomAttributes(true)})
+			remaining -= count
+		case 1:
+			ops = append(ops, gollab.Delete{Count: count})
+			remaining -= count
+		case 2:
+			ops = append(ops, gollab.Insert{
+				Tokens:     richtoken.New(randString(count), randomAttributes(false)),
+				Attributes: randomAttributes(false),
+			})
+		}
+	}
+	if rand.Intn(2) == 0 {
+		ops = append(ops, gollab.Insert{Tokens: richtoken.New(randString(2), nil), Attributes: randomAttributes(false)})
+	}
+	return gollab.NewCompositeOp(ops...)
+}
+
+func randomRichDocument(n int) richtoken.Array {
+	var doc richtoken.Array
+	for len(doc) < n {
+		doc = append(doc, richtoken.New(randString(1), randomAttributes(false))...)
+	}
+	return doc
+}
+
+func applyRich(t *testing.T, op gollab.CompositeOp, doc gollab.TokenArray) gollab.TokenArray {
+	t.Helper()
+	builder := richtoken.ArrayType{}.NewBuilder()
+	if err := op.Apply(gollab.NewTokenArrayReader(doc), builder); err != nil {
+		t.Fatal(err)
+	}
+	fast, err := op.ApplyToTokenArray(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRichEqual(t, builder.TokenArray(), fast)
+	return fast
+}
+
+func assertRichEqual(t *testing.T, a, b gollab.TokenArray) {
+	t.Helper()
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	if string(aJSON) != string(bJSON) {
+		t.Errorf("%s != %s", aJSON, bJSON)
+	}
+}
+
+func TestAttributesApply(t *testing.T) {
+	doc := richtoken.New("Hello", gollab.Attributes{"italic": true})
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 2, Attributes: gollab.Attributes{"bold": true, "italic": nil}},
+		gollab.Retain{Count: 3},
+		gollab.Insert{Tokens: richtoken.New("!", nil), Attributes: gollab.Attributes{"color": "red"}},
+	)
+
+	applied := applyRich(t, op, doc)
+	data, err := json.Marshal(applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[{"text":"He","attributes":{"bold":true}},{"text":"llo","attributes":{"italic":true}},` +
+		`{"text":"!","attributes":{"color":"red"}}]`; string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+}
+
+func TestAttributesJoin(t *testing.T) {
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 1, Attributes: gollab.Attributes{"bold": true}},
+		gollab.Retain{Count: 1, Attributes: gollab.Attributes{"bold": true}},
+		gollab.Retain{Count: 1},
+		gollab.Retain{Count: 1, Attributes: gollab.Attributes{}},
+		gollab.Insert{Tokens: runetoken.Array("a"), Attributes: gollab.Attributes{"bold": true}},
+		gollab.Insert{Tokens: runetoken.Array("b")},
+	)
+
+	expected := gollab.CompositeOp{
+		gollab.Retain{Count: 2, Attributes: gollab.Attributes{"bold": true}},
+		gollab.Retain{Count: 2},
+		gollab.Insert{Tokens: runetoken.Array("a"), Attributes: gollab.Attributes{"bold": true}},
+		gollab.Insert{Tokens: runetoken.Array("b")},
+	}
+	if !reflect.DeepEqual(op, expected) {
+		t.Errorf("expected %v, got %v", expected, op)
+	}
+	if err := op.Validate(4); err != nil {
+		t.Errorf("expected a valid operation, got %v", err)
+	}
+}
+
+func TestAttributesTransform(t *testing.T) {
+	a := gollab.NewCompositeOp(gollab.Retain{Count: 3, Attributes: gollab.Attributes{"color": "red"}})
+	b := gollab.NewCompositeOp(gollab.Retain{Count: 3, Attributes: gollab.Attributes{"color": "blue", "bold": true}})
+
+	aPrime, bPrime := a.Transform(b)
+	if expected := a; !reflect.DeepEqual(aPrime, expected) {
+		t.Errorf("expected a' = %v, got %v", expected, aPrime)
+	}
+	if expected := gollab.NewCompositeOp(gollab.Retain{Count: 3, Attributes: gollab.Attributes{"bold": true}}); !reflect.
+		DeepEqual(bPrime, expected) {
+		t.Errorf("expected b' = %v, got %v", expected, bPrime)
+	}
+}
+
+func TestAttributesConvergence(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			doc := randomRichDocument(rand.Intn(10) + 1)
+			a, b := randomRichOp(doc.Len()), randomRichOp(doc.Len())
+
+			aPrime, bPrime := a.Transform(b)
+			assertRichEqual(t, applyRich(t, bPrime, applyRich(t, a, doc)), applyRich(t, aPrime, applyRich(t, b, doc)))
+
+			afterA := applyRich(t, a, doc)
+			c := randomRichOp(afterA.Len())
+			assertRichEqual(t, applyRich(t, c, afterA), applyRich(t, a.Compose(c), doc))
+
+			inverse, err := a.Invert(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertRichEqual(t, applyRich(t, inverse, afterA), doc)
+		})
+	}
+}
+
+func TestAttributesJSON(t *testing.T) {
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 2, Attributes: gollab.Attributes{"bold": nil}},
+		gollab.Insert{Tokens: richtoken.New("x", gollab.Attributes{"link": "https://example.com"}),
+			Attributes: gollab.Attributes{"italic": true}},
+	)
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[{"type":"retain","count":2,"attributes":{"bold":null}},{"type":"insert","tokens":` +
+		`[{"text":"x","attributes":{"link":"https://example.com"}}],"attributes":{"italic":true}}]`; string(data) !=
+		expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	decoded, err := gollab.UnmarshalCompositeOp(data, richtoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, op) {
+		t.Errorf("expected %v, got %v", op, decoded)
+	}
+
+	if _, err := gollab.MarshalCompactJSON(op); err != gollab.ErrAttributesNotSupported {
+		t.Errorf("expected ErrAttributesNotSupported, got %v", err)
+	}
+
+	plainOp := gollab.NewCompositeOp(
+		gollab.Retain{Count: 2, Attributes: gollab.Attributes{"bold": true}},
+		gollab.Insert{Tokens: runetoken.Array("x"), Attributes: gollab.Attributes{"italic": true}},
+	)
+	binaryData, err := plainOp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err = gollab.UnmarshalCompositeOpBinary(binaryData, runetoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, plainOp) {
+		t.Errorf("expected %v, got %v", plainOp, decoded)
+	}
+}
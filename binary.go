@@ -0,0 +1,195 @@
+package gollab
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/danielslee/gollab/internal/binenc"
+)
+
+const (
+	binaryNoOp byte = iota
+	binaryRetain
+	binaryDelete
+	binaryInsert
+	binaryFormattedRetain
+	binaryFormattedInsert
+)
+
+// ErrNotBinaryMarshaler is returned when encoding an Insert operation whose tokens don't implement
+// encoding.BinaryMarshaler.
+var ErrNotBinaryMarshaler = errors.New("tokens don't implement encoding.BinaryMarshaler")
+
+// ErrTrailingData is returned when decoding binary data containing unexpected data past its end.
+var ErrTrailingData = errors.New("trailing data")
+
+// TokenArrayBinaryUnmarshaler is implemented by a TokenArrayType able to decode its TokenArrays from the binary form
+// produced by their MarshalBinary method. It is the hook used by UnmarshalCompositeOpBinary.
+type TokenArrayBinaryUnmarshaler interface {
+	UnmarshalTokenArrayBinary(data []byte) (TokenArray, error)
+}
+
+// MarshalBinary encodes a CompositeOp into a compact binary form.
+//
+// The operation is encoded as the number of PrimitiveOps followed by each of them encoded as a varint tag, followed by
+// a varint count for Retain and Delete operations and by length-prefixed tokens for Insert operations. The tokens are
+// encoded using their MarshalBinary method, so they have to implement encoding.BinaryMarshaler. Retain and Insert
+// operations with Attributes use distinct tags and are followed by their length-prefixed attributes encoded as JSON.
+func (c CompositeOp) MarshalBinary() ([]byte, error) {
+	var w binenc.Writer
+	w.WriteUvarint(uint64(len(c)))
+	for _, op := range c {
+		switch op := op.(type) {
+		case NoOp:
+			w.WriteUvarint(uint64(binaryNoOp))
+		case Retain:
+			if len(op.Attributes) > 0 {
+				w.WriteUvarint(uint64(binaryFormattedRetain))
+			} else {
+				w.WriteUvarint(uint64(binaryRetain))
+			}
+			w.WriteVarint(int64(op.Count))
+		case Delete:
+			w.WriteUvarint(uint64(binaryDelete))
+			w.WriteVarint(int64(op.Count))
+		case Insert:
+			marshaler, ok := op.Tokens.(encoding.BinaryMarshaler)
+			if !ok {
+				return nil, ErrNotBinaryMarshaler
+			}
+			tokens, err := marshaler.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			if len(op.Attributes) > 0 {
+				w.WriteUvarint(uint64(binaryFormattedInsert))
+			} else {
+				w.WriteUvarint(uint64(binaryInsert))
+			}
+			w.WriteBytes(tokens)
+		default:
+			return nil, ErrUnknownOp
+		}
+
+		if attributes := attributesOf(op); len(attributes) > 0 {
+			data, err := json.Marshal(attributes)
+			if err != nil {
+				return nil, err
+			}
+			w.WriteBytes(data)
+		}
+	}
+	return w.Bytes(), nil
+}
+
+func readAttributes(r binenc.Reader) (Attributes, error) {
+	data, err := r.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes Attributes
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// UnmarshalTokenArrayBinary decodes a TokenArray from its binary form using the given TokenArrayType, which has to
+// implement TokenArrayBinaryUnmarshaler.
+func UnmarshalTokenArrayBinary(data []byte, t TokenArrayType) (TokenArray, error) {
+	unmarshaler, ok := t.(TokenArrayBinaryUnmarshaler)
+	if !ok {
+		return nil, ErrNoTokenArrayType
+	}
+	return unmarshaler.UnmarshalTokenArrayBinary(data)
+}
+
+// UnmarshalCompositeOpBinary decodes a CompositeOp from its binary form, decoding the tokens of its insert operations
+// using the given TokenArrayType, which has to implement TokenArrayBinaryUnmarshaler.
+//
+// See CompositeOp.MarshalBinary for information on the format.
+func UnmarshalCompositeOpBinary(data []byte, t TokenArrayType) (CompositeOp, error) {
+	r := binenc.NewReader(data)
+	l, err := r.ReadUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	c := make(CompositeOp, l)
+	for i := range c {
+		tag, err := r.ReadUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if tag > uint64(binaryFormattedInsert) {
+			return nil, ErrUnknownOp
+		}
+
+		switch byte(tag) {
+		case binaryNoOp:
+			c[i] = NoOp{}
+		case binaryRetain, binaryFormattedRetain:
+			count, err := r.ReadVarint()
+			if err != nil {
+				return nil, err
+			}
+			c[i] = Retain{Count: int(count)}
+		case binaryDelete:
+			count, err := r.ReadVarint()
+			if err != nil {
+				return nil, err
+			}
+			c[i] = Delete{Count: int(count)}
+		case binaryInsert, binaryFormattedInsert:
+			data, err := r.ReadBytes()
+			if err != nil {
+				return nil, err
+			}
+			tokens, err := UnmarshalTokenArrayBinary(data, t)
+			if err != nil {
+				return nil, err
+			}
+			c[i] = Insert{Tokens: tokens}
+		}
+
+		if tag == uint64(binaryFormattedRetain) || tag == uint64(binaryFormattedInsert) {
+			attributes, err := readAttributes(r)
+			if err != nil {
+				return nil, err
+			}
+			switch op := c[i].(type) {
+			case Retain:
+				op.Attributes = attributes
+				c[i] = op
+			case Insert:
+				op.Attributes = attributes
+				c[i] = op
+			}
+		}
+	}
+
+	if r.Len() > 0 {
+		return nil, ErrTrailingData
+	}
+	return c, nil
+}
+
+// UnmarshalBinary decodes a CompositeOp from its binary form.
+//
+// The type of tokens cannot be inferred, so ErrNoTokenArrayType is returned if the operation contains an insert
+// operation. Use UnmarshalCompositeOpBinary to decode such operations.
+func (c *CompositeOp) UnmarshalBinary(data []byte) error {
+	newC, err := UnmarshalCompositeOpBinary(data, nil)
+	if err != nil {
+		return err
+	}
+
+	*c = newC
+	return nil
+}
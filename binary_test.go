@@ -0,0 +1,142 @@
+package gollab_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/danielslee/gollab/runetoken"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/server"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+
+			data, err := op.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := gollab.UnmarshalCompositeOpBinary(data, runetoken.ArrayType{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(op, decoded) {
+				t.Errorf("decoded(%v) != op(%v)", decoded, op)
+			}
+		})
+	}
+}
+
+func TestBinaryUnmarshalWithoutTokenArrayType(t *testing.T) {
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 300}, gollab.Delete{Count: 1})
+	data, err := op.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded gollab.CompositeOp
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(op, decoded) {
+		t.Errorf("decoded(%v) != op(%v)", decoded, op)
+	}
+
+	data, err = gollab.NewCompositeOp(gollab.Insert{Tokens: runetoken.Array("a")}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.UnmarshalBinary(data); err != gollab.ErrNoTokenArrayType {
+		t.Errorf("expected ErrNoTokenArrayType, got %v", err)
+	}
+}
+
+func TestBinaryServerMessages(t *testing.T) {
+	initMsg := server.InitMessage{
+		Document: runetoken.Array("안녕 hello"),
+		Revision: 3,
+		Presence: []server.PresenceMessage{{ClientID: 1, UserID: "1", Name: "One", Color: "#ff0000",
+			Selection: gollab.Selection{Anchor: 1, Head: 2}, Revision: 3}},
+	}
+	data, err := initMsg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedInit := server.InitMessage{Document: runetoken.Array{}}
+	if err := decodedInit.UnmarshalBinary(data); err != gollab.ErrNoTokenArrayType {
+		t.Errorf("expected ErrNoTokenArrayType, got %v", err)
+	}
+	decodedInit, err = server.UnmarshalInitMessageBinary(data, runetoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(initMsg, decodedInit) {
+		t.Errorf("expected %+v, got %+v", initMsg, decodedInit)
+	}
+
+	opMsg := server.OpMessage{
+		AuthorID: "1",
+		Op:       gollab.NewCompositeOp(gollab.Retain{Count: 8}, gollab.Insert{Tokens: runetoken.Array("!")}),
+		Revision: 3,
+	}
+	data, err = opMsg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedOp, err := server.UnmarshalOpMessageBinary(data, runetoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(opMsg, decodedOp) {
+		t.Errorf("expected %+v, got %+v", opMsg, decodedOp)
+	}
+}
+
+func FuzzBinaryJSONRoundTrip(f *testing.F) {
+	for i := 0; i < 16; i++ {
+		l := rand.Intn(20) + 6
+		data, err := randomCompositeOp(l, l+rand.Intn(10)-5).MarshalBinary()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		op, err := gollab.UnmarshalCompositeOpBinary(data, runetoken.ArrayType{})
+		if err != nil {
+			return
+		}
+
+		binaryData, err := op.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromBinary, err := gollab.UnmarshalCompositeOpBinary(binaryData, runetoken.ArrayType{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jsonData, err := json.Marshal(op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromJSON, err := gollab.UnmarshalCompositeOp(jsonData, runetoken.ArrayType{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(fromBinary, fromJSON) {
+			t.Errorf("fromBinary(%v) != fromJSON(%v)", fromBinary, fromJSON)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package gollab_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/runetoken"
+	"github.com/danielslee/gollab/server"
+)
+
+var (
+	_ server.BlameStore = (*server.MemoryStateStore)(nil)
+	_ server.BlameStore = (*server.FileStateStore)(nil)
+)
+
+// blameAuthors expands spans into the author and revision of each token.
+func blameAuthors(spans []server.BlameSpan) []string {
+	var authors []string
+	for _, s := range spans {
+		for i := s.Start; i < s.End; i++ {
+			authors = append(authors, fmt.Sprintf("%s@%d", s.AuthorID, s.Revision))
+		}
+	}
+	return authors
+}
+
+func TestBlame(t *testing.T) {
+	store := server.NewMemoryStateStore(runetoken.Array("hello"))
+	for _, msg := range []server.OpMessage{
+		{AuthorID: "a", Op: gollab.NewCompositeOp(gollab.Retain{Count: 5},
+			gollab.Insert{Tokens: runetoken.Array(" world")}), Revision: 0},
+		{AuthorID: "b", Op: gollab.NewCompositeOp(gollab.Retain{Count: 2}, gollab.Delete{Count: 5},
+			gollab.Insert{Tokens: runetoken.Array("y, ")}, gollab.Retain{Count: 4}), Revision: 1},
+		{AuthorID: "b", Op: gollab.NewCompositeOp(gollab.Insert{Tokens: runetoken.Array("!")},
+			gollab.Retain{Count: 11}), Revision: 1},
+	} {
+		if err := store.ApplyClient(msg); err != nil {
+			t.Fatalf("ApplyClient: %v", err)
+		}
+		<-store.OperationStream()
+	}
+
+	document, _, _ := store.Current()
+	if string(document.(runetoken.Array)) != "!hey, orld" {
+		t.Fatalf("unexpected document %q", document)
+	}
+
+	spans, err := store.Blame(0, document.Len())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []server.BlameSpan{
+		{Start: 0, End: 1, Attribution: server.Attribution{AuthorID: "b", Revision: 3}},
+		{Start: 1, End: 3},
+		{Start: 3, End: 6, Attribution: server.Attribution{AuthorID: "b", Revision: 2}},
+		{Start: 6, End: 10, Attribution: server.Attribution{AuthorID: "a", Revision: 1}},
+	}
+	for i := range spans {
+		spans[i].Time = expected[i].Time
+	}
+	if !reflect.DeepEqual(spans, expected) {
+		t.Errorf("expected %v, got %v", expected, spans)
+	}
+
+	spans, err = store.Blame(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 2 || spans[0].Start != 2 || spans[0].End != 3 || spans[1].Start != 3 || spans[1].End != 4 {
+		t.Errorf("unexpected spans %v", spans)
+	}
+
+	if _, err := store.Blame(5, 11); !errors.Is(err, server.ErrInvalidRange) {
+		t.Errorf("expected ErrInvalidRange, got %v", err)
+	}
+}
+
+// checkBlameRandom applies random operations to the store, checking Blame against the expected author of each token.
+func checkBlameRandom(t *testing.T, store server.BlameStore, authors []string) []string {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		document, revision, _ := store.Current()
+		op := randomCompositeOp(document.Len(), document.Len()/2+rand.Intn(10))
+		author := fmt.Sprint(rand.Intn(3))
+		if err := store.ApplyClient(server.OpMessage{AuthorID: author, Op: op, Revision: revision}); err != nil {
+			t.Fatalf("ApplyClient: %v", err)
+		}
+		<-store.OperationStream()
+
+		var next []string
+		var pos int
+		for _, p := range op {
+			switch p := p.(type) {
+			case gollab.Retain:
+				next = append(next, authors[pos:pos+p.Count]...)
+				pos += p.Count
+			case gollab.Delete:
+				pos += p.Count
+			case gollab.Insert:
+				for j := 0; j < p.Tokens.Len(); j++ {
+					next = append(next, fmt.Sprintf("%s@%d", author, revision+1))
+				}
+			}
+		}
+		authors = next
+
+		spans, err := store.Blame(0, len(authors))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := blameAuthors(spans); len(got) != len(authors) || len(got) > 0 && !reflect.DeepEqual(got, authors) {
+			t.Fatalf("expected %v, got %v", authors, got)
+		}
+		for j := 1; j < len(spans); j++ {
+			if spans[j].Attribution.Equal(spans[j-1].Attribution) {
+				t.Fatalf("expected adjacent spans to have different attributions, got %v", spans)
+			}
+		}
+	}
+	return authors
+}
+
+func TestBlameRandom(t *testing.T) {
+	store := server.NewMemoryStateStore(runetoken.Array("hello world"))
+	authors := make([]string, 11)
+	for i := range authors {
+		authors[i] = "@0"
+	}
+	checkBlameRandom(t, store, authors)
+}
+
+func TestFileStateStoreBlame(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStateStore(t, dir, 7)
+	authors := checkBlameRandom(t, store, nil)
+	document, _, _ := store.Current()
+	expected, err := store.Blame(0, document.Len())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store = openFileStateStore(t, dir, 7)
+	spans, err := store.Blame(0, document.Len())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != len(expected) {
+		t.Fatalf("expected %v after reopening, got %v", expected, spans)
+	}
+	for i := range spans {
+		if spans[i].Start != expected[i].Start || spans[i].End != expected[i].End ||
+			!spans[i].Attribution.Equal(expected[i].Attribution) {
+			t.Errorf("expected %v after reopening, got %v", expected[i], spans[i])
+		}
+	}
+	checkBlameRandom(t, store, authors)
+	store.Close()
+}
@@ -0,0 +1,174 @@
+/*
+Package bytetoken provides an implementation of the gollab.TokenReader, gollab.TokenWriter and gollab.TokenArray
+for byte slices, allowing byte-addressed content such as binary files to be edited collaboratively.
+
+Arrays are encoded to JSON as base64 strings.
+*/
+package bytetoken
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/danielslee/gollab"
+)
+
+// Array implements the TokenArray interface as a byte slice.
+type Array []byte
+
+// Type returns the ArrayType.
+func (Array) Type() gollab.TokenArrayType {
+	return ArrayType{}
+}
+
+// At returns an element given its index.
+func (t Array) At(idx int) interface{} {
+	return t[idx]
+}
+
+// TokenAt returns an element given its index. It implements gollab.TypedTokenArray.
+func (t Array) TokenAt(idx int) byte {
+	return t[idx]
+}
+
+// AppendTokens appends the bytes between start and end to dst. It implements gollab.TypedTokenArray.
+func (t Array) AppendTokens(dst []byte, start, end int) []byte {
+	return append(dst, t[start:end]...)
+}
+
+// Slice slices the array.
+func (t Array) Slice(start, end int) gollab.TokenArray {
+	return t[start:end]
+}
+
+// Len returns the length of the array.
+func (t Array) Len() int {
+	return len(t)
+}
+
+// MarshalJSON encodes the Array as a base64 JSON string.
+func (t Array) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]byte(t))
+}
+
+// MarshalBinary returns the bytes of the Array.
+func (t Array) MarshalBinary() ([]byte, error) {
+	return t, nil
+}
+
+// ApplyOp applies an operation to the array using gollab.ApplyTokens, returning the resulting Array. It implements
+// gollab.TokenArrayEditor.
+func (t Array) ApplyOp(op gollab.CompositeOp) (gollab.TokenArray, error) {
+	res, err := gollab.ApplyTokens(op, []byte(t))
+	if err != nil {
+		return nil, err
+	}
+	return Array(res), nil
+}
+
+// ArrayType contains methods related to Array.
+type ArrayType struct{}
+
+// NewBuilder creates a new ArrayBuilder.
+func (ArrayType) NewBuilder() gollab.TokenArrayBuilder {
+	return &ArrayBuilder{}
+}
+
+// Concat concatenates two TokenArrays and returns the result.
+func (ArrayType) Concat(a, b gollab.TokenArray) gollab.TokenArray {
+	ba, ok := a.(Array)
+	if !ok {
+		panic("ArrayType.Concat: expected param a to be of type Array")
+	}
+	bb, ok := b.(Array)
+	if !ok {
+		panic("ArrayType.Concat: expected param b to be of type Array")
+	}
+
+	newTokens := make(Array, len(ba)+len(bb))
+	copy(newTokens, ba)
+	copy(newTokens[len(ba):], bb)
+	return newTokens
+}
+
+// UnmarshalTokenArray decodes an Array from a base64 JSON string. It implements gollab.TokenArrayUnmarshaler.
+func (ArrayType) UnmarshalTokenArray(data []byte) (gollab.TokenArray, error) {
+	var b []byte
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+	return Array(b), nil
+}
+
+// UnmarshalTokenArrayBinary returns the bytes as an Array. It implements gollab.TokenArrayBinaryUnmarshaler.
+func (ArrayType) UnmarshalTokenArrayBinary(data []byte) (gollab.TokenArray, error) {
+	return Array(data), nil
+}
+
+// ArrayBuilder is a TokenArrayBuilder implementation using a bytes.Buffer.
+type ArrayBuilder struct {
+	buf bytes.Buffer
+}
+
+// Write appends a given byte to the builder. It implements gollab.TypedTokenArrayBuilder.
+func (b *ArrayBuilder) Write(c byte) error {
+	return b.buf.WriteByte(c)
+}
+
+// WriteToken appends a given token to the builder.
+func (b *ArrayBuilder) WriteToken(token interface{}) error {
+	c, ok := token.(byte)
+	if !ok {
+		return errors.New("ArrayBuilder.WriteToken: expected a byte")
+	}
+	return b.Write(c)
+}
+
+// Bytes returns the built bytes.
+func (b *ArrayBuilder) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// TokenArray returns the built Array.
+func (b *ArrayBuilder) TokenArray() gollab.TokenArray {
+	return Array(b.Bytes())
+}
+
+// BytesReader implements a TokenReader using a bytes.Reader.
+type BytesReader struct {
+	Reader *bytes.Reader
+}
+
+// ReadToken reads and returns a byte using the internal bytes.Reader.
+func (r BytesReader) ReadToken() (interface{}, error) {
+	c, err := r.Reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ApplyToBytes applies the operation to a byte slice, returning the result as a new byte slice. The operation is
+// validated first, using gollab.ApplyTokens for a CompositeOp, so that it never reads past the end of data. This
+// assumes the operation consumes and outputs bytes only.
+func ApplyToBytes(op gollab.Op, data []byte) ([]byte, error) {
+	if c, ok := op.(gollab.CompositeOp); ok {
+		return gollab.ApplyTokens(c, data)
+	}
+
+	if validator, ok := op.(gollab.Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if op.InputLength() != len(data) {
+		return nil, gollab.ErrLengthMismatch
+	}
+
+	var out ArrayBuilder
+	if err := op.Apply(BytesReader{Reader: bytes.NewReader(data)}, &out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,123 @@
+package gollab_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/bytetoken"
+)
+
+var (
+	_ gollab.TypedTokenArray[byte]        = bytetoken.Array{}
+	_ gollab.TypedTokenArrayBuilder[byte] = &bytetoken.ArrayBuilder{}
+)
+
+func TestApplyToBytes(t *testing.T) {
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 2},
+		gollab.Delete{Count: 2},
+		gollab.Insert{Tokens: bytetoken.Array{0xca, 0xfe}},
+		gollab.Retain{Count: 1},
+	)
+
+	applied, err := bytetoken.ApplyToBytes(op, []byte{0x00, 0x01, 0xff, 0xfe, 0x02})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte{0x00, 0x01, 0xca, 0xfe, 0x02}; !bytes.Equal(applied, expected) {
+		t.Errorf("expected %x, got %x", expected, applied)
+	}
+
+	if _, err := bytetoken.ApplyToBytes(op, []byte{0x00}); err != gollab.ErrLengthMismatch {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
+
+func TestBytesApplyPaths(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			base := make([]byte, rand.Intn(20)+1)
+			rand.Read(base)
+
+			op := gollab.NewCompositeOp(
+				gollab.Retain{Count: rand.Intn(len(base))},
+				gollab.Insert{Tokens: bytetoken.Array{byte(rand.Intn(256))}},
+			)
+			op = gollab.NewCompositeOp(append(op, gollab.Delete{Count: len(base) - op.InputLength()})...)
+
+			expected, err := bytetoken.ApplyToBytes(op, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			builder := bytetoken.ArrayType{}.NewBuilder()
+			if err := op.Apply(gollab.NewTokenArrayReader(bytetoken.Array(base)), builder); err != nil {
+				t.Fatal(err)
+			}
+			if applied := builder.TokenArray().(bytetoken.Array); !bytes.Equal(applied, expected) {
+				t.Errorf("expected %x, got %x", expected, applied)
+			}
+		})
+	}
+}
+
+func TestBytesJSON(t *testing.T) {
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 1}, gollab.Insert{Tokens: bytetoken.Array{0x00, 0xff, 0x10}})
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[{"type":"retain","count":1},{"type":"insert","tokens":"AP8Q"}]`; string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	decoded, err := gollab.UnmarshalCompositeOp(data, bytetoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, op) {
+		t.Errorf("expected %v, got %v", op, decoded)
+	}
+
+	binaryData, err := op.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err = gollab.UnmarshalCompositeOpBinary(binaryData, bytetoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, op) {
+		t.Errorf("expected %v, got %v", op, decoded)
+	}
+}
+
+func TestBytesApplyInvalid(t *testing.T) {
+	buf := []byte("ab SECRET")
+	for _, op := range []gollab.Op{
+		gollab.CompositeOp{gollab.Retain{Count: 5}, gollab.Delete{Count: -3}},
+		gollab.CompositeOp{gollab.Delete{Count: -1}, gollab.Retain{Count: 3}},
+		gollab.Retain{Count: -1},
+	} {
+		if applied, err := bytetoken.ApplyToBytes(op, buf[:2]); !errors.Is(err, gollab.ErrInvalidCount) {
+			t.Errorf("%v: expected ErrInvalidCount, got %q (%v)", op, applied, err)
+		}
+	}
+
+	for _, op := range []gollab.Op{
+		gollab.CompositeOp{gollab.Retain{Count: 5}},
+		gollab.Retain{Count: 5},
+	} {
+		if applied, err := bytetoken.ApplyToBytes(op, buf[:2]); err != gollab.ErrLengthMismatch {
+			t.Errorf("%v: expected ErrLengthMismatch, got %q (%v)", op, applied, err)
+		}
+	}
+}
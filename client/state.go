@@ -1,5 +1,6 @@
 /*
-Package client implements an immutable State struct representing the client's state.
+Package client implements an immutable State struct representing the client's state, and an UndoManager providing
+undo and redo of local operations on top of it.
 */
 package client
 
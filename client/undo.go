@@ -0,0 +1,137 @@
+package client
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danielslee/gollab"
+)
+
+// ErrNothingToUndo is returned by UndoManager.Undo when the undo stack is empty.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ErrNothingToRedo is returned by UndoManager.Redo when the redo stack is empty.
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// UndoManager implements selective undo and redo of local operations in a collaborative setting.
+//
+// Local operations are recorded along with the document they were applied to, which is needed to compute their
+// inverse. Every operation received from the server has to be passed to Transform (after it's been transformed by
+// State.ApplyServerOp), so that undoing a local operation never reverts changes made by other clients.
+//
+// Operations recorded within GroupInterval of each other are grouped into a single undo step.
+//
+// Unlike State, UndoManager is mutable. It is not safe for concurrent use.
+type UndoManager struct {
+	groupInterval time.Duration
+
+	undoStack []gollab.CompositeOp
+	redoStack []gollab.CompositeOp
+
+	lastRecorded time.Time
+	canGroup     bool
+}
+
+// NewUndoManager creates a new UndoManager grouping operations recorded within groupInterval of each other. A zero
+// groupInterval disables grouping.
+func NewUndoManager(groupInterval time.Duration) *UndoManager {
+	return &UndoManager{
+		groupInterval: groupInterval,
+	}
+}
+
+// Record records a local operation given the document it was applied to. This clears the redo stack.
+//
+// Operations returned by Undo and Redo must not be recorded.
+func (u *UndoManager) Record(op gollab.CompositeOp, document gollab.TokenArray) error {
+	inverse, err := op.Invert(document)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if top := len(u.undoStack) - 1; top >= 0 && u.canGroup && now.Sub(u.lastRecorded) < u.groupInterval {
+		if inverse.OutputLength() != u.undoStack[top].InputLength() {
+			return gollab.ErrLengthMismatch
+		}
+		u.undoStack[top] = inverse.Compose(u.undoStack[top])
+	} else {
+		u.undoStack = append(u.undoStack, inverse)
+	}
+
+	u.lastRecorded = now
+	u.canGroup = true
+	u.redoStack = nil
+	return nil
+}
+
+// Transform transforms the undo and redo stacks against an operation made by another client. The operation has to be
+// the documentOp returned by State.ApplyServerOp, i.e. one which can be applied to the client's document.
+func (u *UndoManager) Transform(op gollab.CompositeOp) {
+	transformStack(u.undoStack, op)
+	transformStack(u.redoStack, op)
+}
+
+func transformStack(stack []gollab.CompositeOp, op gollab.CompositeOp) {
+	for i := len(stack) - 1; i >= 0; i-- {
+		stack[i], op = stack[i].Transform(op)
+	}
+}
+
+// BreakGroup ensures the next recorded operation starts a new undo step, regardless of GroupInterval.
+func (u *UndoManager) BreakGroup() {
+	u.canGroup = false
+}
+
+// CanUndo returns true if there is anything to undo.
+func (u *UndoManager) CanUndo() bool {
+	return len(u.undoStack) > 0
+}
+
+// CanRedo returns true if there is anything to redo.
+func (u *UndoManager) CanRedo() bool {
+	return len(u.redoStack) > 0
+}
+
+// Undo pops the last undo step given the current document. The returned operation should be applied to the document
+// and passed to State.ApplyClientOp.
+func (u *UndoManager) Undo(document gollab.TokenArray) (gollab.CompositeOp, error) {
+	op, redo, err := popInverse(&u.undoStack, document, ErrNothingToUndo)
+	if err != nil {
+		return nil, err
+	}
+
+	u.redoStack = append(u.redoStack, redo)
+	u.canGroup = false
+	return op, nil
+}
+
+// Redo pops the last redo step given the current document. The returned operation should be applied to the document
+// and passed to State.ApplyClientOp.
+func (u *UndoManager) Redo(document gollab.TokenArray) (gollab.CompositeOp, error) {
+	op, undo, err := popInverse(&u.redoStack, document, ErrNothingToRedo)
+	if err != nil {
+		return nil, err
+	}
+
+	u.undoStack = append(u.undoStack, undo)
+	u.canGroup = false
+	return op, nil
+}
+
+func popInverse(stack *[]gollab.CompositeOp, document gollab.TokenArray, errEmpty error) (op,
+	inverse gollab.CompositeOp, err error) {
+	top := len(*stack) - 1
+	if top < 0 {
+		return nil, nil, errEmpty
+	}
+
+	op = (*stack)[top]
+	inverse, err = op.Invert(document)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	*stack = (*stack)[:top]
+	return op, inverse, nil
+}
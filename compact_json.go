@@ -0,0 +1,87 @@
+package gollab
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrAttributesNotSupported is returned when encoding an operation with attributes into a format not supporting them.
+var ErrAttributesNotSupported = errors.New("attributes not supported")
+
+// MarshalCompactJSON encodes a CompositeOp into the compact JSON format used by ot.js, which is an array where:
+//
+//	a positive integer n represents Retain(n)
+//	a negative integer -n represents Delete(n)
+//	anything else represents an Insert, encoded using its tokens' MarshalJSON method
+//
+// For example, Delete(1), Insert("H"), Retain(4), Insert(", World") is encoded as:
+//
+//	["H", -1, 4, ", World"]
+//
+// Tokens encoded as a JSON string (such as runetoken.Array) are compatible with ot.js text operations. NoOps and
+// operations with a zero count are omitted. The format cannot represent Attributes, so ErrAttributesNotSupported is
+// returned for operations carrying them.
+func MarshalCompactJSON(c CompositeOp) ([]byte, error) {
+	ops := make([]interface{}, 0, len(c))
+	for _, op := range c {
+		if len(attributesOf(op)) > 0 {
+			return nil, ErrAttributesNotSupported
+		}
+
+		switch op := op.(type) {
+		case NoOp:
+			continue
+		case Retain:
+			if op.Count != 0 {
+				ops = append(ops, op.Count)
+			}
+		case Delete:
+			if op.Count != 0 {
+				ops = append(ops, -op.Count)
+			}
+		case Insert:
+			ops = append(ops, op.Tokens)
+		default:
+			return nil, ErrUnknownOp
+		}
+	}
+
+	return json.Marshal(ops)
+}
+
+// UnmarshalCompactJSON decodes a CompositeOp from the compact JSON format used by ot.js, decoding the tokens of its
+// insert operations using the given TokenArrayType, which has to implement TokenArrayUnmarshaler.
+//
+// See MarshalCompactJSON for information on the format.
+func UnmarshalCompactJSON(data []byte, t TokenArrayType) (CompositeOp, error) {
+	var ops []json.RawMessage
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, err
+	}
+
+	c := make(CompositeOp, len(ops))
+	for i, op := range ops {
+		if len(op) > 0 && (op[0] == '-' || (op[0] >= '0' && op[0] <= '9')) {
+			var count int
+			if err := json.Unmarshal(op, &count); err != nil {
+				return nil, err
+			}
+
+			if count > 0 {
+				c[i] = Retain{Count: count}
+			} else if count < 0 {
+				c[i] = Delete{Count: -count}
+			} else {
+				return nil, ErrInvalidCount
+			}
+			continue
+		}
+
+		tokens, err := UnmarshalTokenArray(op, t)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = Insert{Tokens: tokens}
+	}
+	return c, nil
+}
@@ -1,5 +1,7 @@
 package gollab
 
+import "errors"
+
 // CompositeOp is an operation composed of multiple PrimitiveOps.
 //
 // CompositeOp implements MarshalJSON and UnmarshalJSON allowing it to be encoded to/decoded from JSON as an array of
@@ -11,11 +13,15 @@ package gollab
 //	 {"type": "delete", "count": 1},
 //	 {"type": "insert", "tokens": ...}
 //	]
-// The tokens in an insert operation will be serialized based on its MarshalJSON and UnmarshalJSON methods.
+// Retain and insert operations carry an additional attributes object if they have any Attributes.
+// The tokens in an insert operation will be serialized based on its MarshalJSON method. As the type of tokens cannot be
+// inferred when decoding, operations containing inserts have to be decoded using UnmarshalCompositeOp.
+//
+// See MarshalCompactJSON and UnmarshalCompactJSON for an alternative, more compact format compatible with ot.js.
 type CompositeOp []PrimitiveOp
 
 // NewCompositeOp creates a new CompositeOp, normalizing the given PrimitiveOps as needed. (i.e. multiple consecutive
-// operations of the same type will be merged into one)
+// operations of the same type will be merged into one, and NoOps as well as operations of zero tokens removed)
 func NewCompositeOp(ops ...PrimitiveOp) CompositeOp {
 	return normalize(ops)
 }
@@ -47,6 +53,25 @@ func (c CompositeOp) Apply(reader TokenReader, writer TokenWriter) error {
 	return nil
 }
 
+// ApplyToTokenArray applies the operation to a TokenArray, returning the resulting TokenArray of the same type.
+//
+// If the TokenArray implements TokenArrayEditor, its ApplyOp method is used. Otherwise the tokens are read one by one
+// and written to a builder created by the TokenArray's type. The operation is checked using ValidateApply first.
+func (c CompositeOp) ApplyToTokenArray(t TokenArray) (TokenArray, error) {
+	if err := c.ValidateApply(t.Len()); err != nil {
+		return nil, err
+	}
+	if editor, ok := t.(TokenArrayEditor); ok {
+		return editor.ApplyOp(c)
+	}
+
+	builder := t.Type().NewBuilder()
+	if err := c.Apply(NewTokenArrayReader(t), builder); err != nil {
+		return nil, err
+	}
+	return builder.TokenArray(), nil
+}
+
 // Transform implements OT - Operation Transformation.
 //
 // Suppose we have two operations which were applied simultaneously.
@@ -66,33 +91,119 @@ func (c CompositeOp) Apply(reader TokenReader, writer TokenWriter) error {
 // We can verify that the above transformation is correct by applying `a` to `hello`, resulting in `Hello, World`, which
 // gives us `Hello, World!` after applying `b'` to it. We arrive to the same result by applying `b` to hello getting
 // `hello!` followed by applying `a'` making it into `Hello, World!`.
+//
+// When both operations insert at the same position, the tokens inserted by `a` (the receiver) are placed first. See
+// TransformWithPriority for choosing the order explicitly.
 func (c CompositeOp) Transform(b CompositeOp) (aPrime, bPrime CompositeOp) {
-	slicedA, slicedB := slice(c, b,
-		inputLengthFunc, inputLengthFunc)
+	aPrime, bPrime, err := c.TryTransform(b)
+	if err != nil {
+		panic(errors.Unwrap(err))
+	}
+	return
+}
+
+// TryTransform performs Operation Transformation just like Transform, but returns an error instead of panicking if the
+// operations cannot be transformed. The error will be either a *LengthMismatchError or an *UnexpectedOpError.
+func (c CompositeOp) TryTransform(b CompositeOp) (aPrime, bPrime CompositeOp, err error) {
+	pairs, err := trySlice(c, b, inputLengthFunc, inputLengthFunc)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	for i := range slicedA {
-		aOp, bOp := slicedA[i], slicedB[i]
-		aOpPrime, bOpPrime := aOp.Transform(bOp)
+	for _, p := range pairs {
+		aOpPrime, bOpPrime, err := tryTransform(p.a, p.b)
+		if err != nil {
+			return nil, nil, p.wrapError(err, inputLengthFunc, inputLengthFunc)
+		}
 		aPrime = append(aPrime, aOpPrime)
 		bPrime = append(bPrime, bOpPrime)
 	}
-	return NewCompositeOp(aPrime...), NewCompositeOp(bPrime...)
+	return NewCompositeOp(aPrime...), NewCompositeOp(bPrime...), nil
+}
+
+// TransformWithPriority performs Operation Transformation just like Transform, but lets the caller decide which
+// operation's tokens are placed first when both operations insert at the same position.
+//
+// If aWins is true, the tokens inserted by `a` (the receiver) are placed first, otherwise the ones inserted by `b` are.
+// Sites transforming the same pair of operations have to agree on aWins to converge, e.g. by comparing site ids:
+//
+//	aPrime, bPrime := a.TransformWithPriority(b, aSiteID < bSiteID)
+func (c CompositeOp) TransformWithPriority(b CompositeOp, aWins bool) (aPrime, bPrime CompositeOp) {
+	if aWins {
+		return c.Transform(b)
+	}
+	bPrime, aPrime = b.Transform(c)
+	return
 }
 
 // Compose composes two operations which happened in order into one.
 //
 // See also: func Compose which takes any number of operations as opposed to two and the attached example.
 func (c CompositeOp) Compose(b CompositeOp) CompositeOp {
-	slicedA, slicedB := slice(c, b,
-		outputLengthFunc, inputLengthFunc)
+	res, err := c.TryCompose(b)
+	if err != nil {
+		panic(errors.Unwrap(err))
+	}
+	return res
+}
+
+// TryCompose composes two operations just like Compose, but returns an error instead of panicking if the operations
+// cannot be composed. The error will be either a *LengthMismatchError or an *UnexpectedOpError.
+func (c CompositeOp) TryCompose(b CompositeOp) (CompositeOp, error) {
+	pairs, err := trySlice(c, b, outputLengthFunc, inputLengthFunc)
+	if err != nil {
+		return nil, err
+	}
 
 	var res []PrimitiveOp
-	for i := range slicedA {
-		aOp, bOp := slicedA[i], slicedB[i]
-		c := aOp.Compose(bOp)
-		res = append(res, c)
+	for _, p := range pairs {
+		composed, err := tryCompose(p.a, p.b)
+		if err != nil {
+			return nil, p.wrapError(err, outputLengthFunc, inputLengthFunc)
+		}
+		res = append(res, composed)
+	}
+	return NewCompositeOp(res...), nil
+}
+
+// Invert returns the inverse of the operation given the document it is applied to (base).
+//
+// Applying the operation to base followed by its inverse yields base again. This is the building block for implementing
+// undo. The length of base has to equal the operation's InputLength, and all PrimitiveOps have to implement Inverter,
+// otherwise ErrUnexpectedOp is returned.
+func (c CompositeOp) Invert(base TokenArray) (CompositeOp, error) {
+	if c.InputLength() != base.Len() {
+		return nil, ErrLengthMismatch
+	}
+
+	var idx int
+	inverse := make([]PrimitiveOp, 0, len(c))
+	for _, p := range c {
+		l := p.InputLength()
+		if r, ok := p.(Retain); ok && len(r.Attributes) > 0 {
+			inverse = append(inverse, invertFormatting(r, base.Slice(idx, idx+l))...)
+		} else if inverter, ok := p.(Inverter); ok {
+			inverse = append(inverse, inverter.Invert(base.Slice(idx, idx+l)))
+		} else {
+			return nil, ErrUnexpectedOp
+		}
+		idx += l
+	}
+	return NewCompositeOp(inverse...), nil
+}
+
+// invertFormatting inverts a Retain operation with attributes, splitting it into runs of tokens with equal attributes.
+func invertFormatting(r Retain, input TokenArray) []PrimitiveOp {
+	var inverse []PrimitiveOp
+	start := 0
+	for i := 1; i <= input.Len(); i++ {
+		if i < input.Len() && tokenAttributes(input.At(i)).Equal(tokenAttributes(input.At(start))) {
+			continue
+		}
+		inverse = append(inverse, r.Slice(start, i).(Retain).Invert(input.Slice(start, i)))
+		start = i
 	}
-	return NewCompositeOp(res...)
+	return inverse
 }
 
 // Compose composes multiple operations which happened in order into one.
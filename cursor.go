@@ -0,0 +1,70 @@
+package gollab
+
+// TransformIndex transforms a position within a document (e.g. a caret) through an operation, returning its new
+// position within the operation's output.
+//
+// A position is an index between two tokens, 0 being before the first token and the document's length being after the
+// last one. Positions inside a deleted range collapse to where the range used to be. stickToEnd decides what happens
+// when tokens are inserted exactly at the position: if true the position is moved after the inserted tokens, otherwise
+// it stays in front of them.
+func TransformIndex(pos int, op CompositeOp, stickToEnd bool) int {
+	var in, out int
+	for _, p := range op {
+		switch p := p.(type) {
+		case Retain:
+			if pos < in+p.Count {
+				return out + pos - in
+			}
+			in += p.Count
+			out += p.Count
+		case Delete:
+			if pos < in+p.Count {
+				return out
+			}
+			in += p.Count
+		case Insert:
+			if pos == in && !stickToEnd {
+				return out
+			}
+			out += p.OutputLength()
+		}
+	}
+	return out
+}
+
+// Selection represents a range within a document, such as a user's selection. Anchor is the position where the
+// selection started and Head the position where it ends, which is where the caret is displayed. Head can be before
+// Anchor. A Selection where Anchor equals Head represents a plain caret.
+type Selection struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// Start returns the lower of the selection's positions.
+func (s Selection) Start() int {
+	if s.Anchor < s.Head {
+		return s.Anchor
+	}
+	return s.Head
+}
+
+// End returns the higher of the selection's positions.
+func (s Selection) End() int {
+	if s.Anchor > s.Head {
+		return s.Anchor
+	}
+	return s.Head
+}
+
+// Collapsed returns true if the selection is empty, i.e. it's a caret.
+func (s Selection) Collapsed() bool {
+	return s.Anchor == s.Head
+}
+
+// Transform transforms the selection through an operation. See TransformIndex for the meaning of stickToEnd.
+func (s Selection) Transform(op CompositeOp, stickToEnd bool) Selection {
+	return Selection{
+		Anchor: TransformIndex(s.Anchor, op, stickToEnd),
+		Head:   TransformIndex(s.Head, op, stickToEnd),
+	}
+}
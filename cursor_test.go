@@ -0,0 +1,100 @@
+package gollab_test
+
+import (
+	"fmt"
+	"github.com/danielslee/gollab/runetoken"
+	"math/rand"
+	"testing"
+
+	"github.com/danielslee/gollab"
+)
+
+func TestTransformIndex(t *testing.T) {
+	// hello -> Hello, World
+	op := gollab.NewCompositeOp(
+		gollab.Delete{Count: 1},
+		gollab.Insert{Tokens: runetoken.Array("H")},
+		gollab.Retain{Count: 4},
+		gollab.Insert{Tokens: runetoken.Array(", World")})
+
+	for _, test := range []struct {
+		pos        int
+		stickToEnd bool
+		expected   int
+	}{
+		{0, false, 0},
+		{0, true, 1},
+		{1, false, 1},
+		{3, false, 3},
+		{5, false, 5},
+		{5, true, 12},
+	} {
+		if res := gollab.TransformIndex(test.pos, op, test.stickToEnd); res != test.expected {
+			t.Errorf("TransformIndex(%d, stickToEnd: %v): expected %d, got %d", test.pos, test.stickToEnd,
+				test.expected, res)
+		}
+	}
+}
+
+func TestTransformIndexDelete(t *testing.T) {
+	// hello world -> hello
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 5}, gollab.Delete{Count: 6})
+
+	for pos, expected := range []int{0, 1, 2, 3, 4, 5, 5, 5, 5, 5, 5, 5} {
+		if res := gollab.TransformIndex(pos, op, false); res != expected {
+			t.Errorf("TransformIndex(%d): expected %d, got %d", pos, expected, res)
+		}
+	}
+}
+
+func TestTransformIndexRetainedTokens(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+
+			// a position right in front of a retained token has to end up right in front of it when sticking to the
+			// end, or in front of any tokens inserted before it otherwise
+			var in, out int
+			for _, p := range op {
+				if r, ok := p.(gollab.Retain); ok {
+					for j := 0; j < r.Count; j++ {
+						if res := gollab.TransformIndex(in+j, op, true); res != out+j {
+							t.Errorf("TransformIndex(%d, true): expected %d, got %d, op: %v", in+j, out+j, res, op)
+						}
+						if res := gollab.TransformIndex(in+j, op, false); res > out+j {
+							t.Errorf("TransformIndex(%d, false): expected at most %d, got %d, op: %v", in+j, out+j,
+								res, op)
+						}
+					}
+				}
+				in += p.InputLength()
+				out += p.OutputLength()
+			}
+
+			prev := 0
+			for pos := 0; pos <= l; pos++ {
+				res := gollab.TransformIndex(pos, op, rand.Intn(2) == 0)
+				if res < prev || res > op.OutputLength() {
+					t.Errorf("TransformIndex(%d) = %d out of order, op: %v", pos, res, op)
+				}
+				prev = res
+			}
+		})
+	}
+}
+
+func TestSelectionTransform(t *testing.T) {
+	// hello world -> hello, brave world
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 5}, gollab.Insert{Tokens: runetoken.Array(", brave")},
+		gollab.Retain{Count: 6})
+
+	sel := gollab.Selection{Anchor: 11, Head: 6}.Transform(op, false)
+	if sel.Anchor != 18 || sel.Head != 13 {
+		t.Errorf("expected Selection{18, 13}, got %v", sel)
+	}
+	if sel.Start() != 13 || sel.End() != 18 || sel.Collapsed() {
+		t.Errorf("unexpected Start(%d), End(%d), Collapsed(%v)", sel.Start(), sel.End(), sel.Collapsed())
+	}
+}
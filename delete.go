@@ -60,13 +60,23 @@ func (d Delete) Swap(next PrimitiveOp) bool {
 //
 // The output length of the first operation has to equal the input length of the other one.
 func (d Delete) Compose(other PrimitiveOp) PrimitiveOp {
-	checkComposeLength(d, other)
+	c, err := d.tryCompose(other)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (d Delete) tryCompose(other PrimitiveOp) (PrimitiveOp, error) {
+	if err := checkComposeLength(d, other); err != nil {
+		return nil, err
+	}
 
 	switch other.(type) {
 	case NoOp:
-		return d
+		return d, nil
 	default:
-		panic(ErrUnexpectedOp)
+		return nil, ErrUnexpectedOp
 	}
 }
 
@@ -74,18 +84,45 @@ func (d Delete) Compose(other PrimitiveOp) PrimitiveOp {
 //
 // The input length of both operations has to be equal.
 func (d Delete) Transform(other PrimitiveOp) (aPrime, bPrime PrimitiveOp) {
-	checkTransformLength(d, other)
+	aPrime, bPrime, err := d.tryTransform(other)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (d Delete) tryTransform(other PrimitiveOp) (aPrime, bPrime PrimitiveOp, err error) {
+	if err := checkTransformLength(d, other); err != nil {
+		return nil, nil, err
+	}
 
 	switch b := other.(type) {
 	case Delete:
-		return NoOp{}, NoOp{}
+		return NoOp{}, NoOp{}, nil
 	case Retain:
-		return Delete{Count: b.Count}, NoOp{}
+		return Delete{Count: b.Count}, NoOp{}, nil
 	default:
-		panic(ErrUnexpectedOp)
+		return nil, nil, ErrUnexpectedOp
 	}
 }
 
+// Invert returns the inverse of the operation given the tokens it consumes.
+//
+// A Delete operation doesn't carry the tokens it removes, so these have to be supplied as input. The inverse is an
+// Insert operation re-inserting them.
+func (d Delete) Invert(input TokenArray) PrimitiveOp {
+	checkInvertLength(d, input)
+	return Insert{Tokens: input}
+}
+
+// Validate checks whether the operation is valid, returning ErrInvalidCount if its Count isn't positive.
+func (d Delete) Validate() error {
+	if d.Count <= 0 {
+		return ErrInvalidCount
+	}
+	return nil
+}
+
 // String provides a string representation of the Delete operation.
 func (d Delete) String() string {
 	return fmt.Sprintf("Delete(%d)", d.Count)
@@ -0,0 +1,182 @@
+package gollab
+
+// Diff computes an operation turning old into new, i.e. one which produces new when applied to old. The operation is
+// minimal, retaining as many tokens as possible, and normalized.
+//
+// Tokens implementing EqualToken are compared using their Equal method, others using ==, so they have to be
+// comparable. Diff uses the linear space variant of Myers' algorithm, which takes O((N+M)D) time and O(N+M) space,
+// where N and M are the lengths of old and new and D is the number of inserted and deleted tokens.
+func Diff(old, new TokenArray) CompositeOp {
+	return DiffFunc(old, new, func(i, j int) bool {
+		a, b := old.At(i), new.At(j)
+		if equal, ok := a.(EqualToken); ok {
+			return equal.Equal(b)
+		}
+		return a == b
+	})
+}
+
+// EqualToken is implemented by tokens which cannot be compared using ==, such as tokens carrying Attributes. It is used
+// by Diff to compare them.
+type EqualToken interface {
+	// Equal reports whether the token equals another token.
+	Equal(other interface{}) bool
+}
+
+// DiffFunc works like Diff, comparing tokens using equal, which is given the index of a token within old and the index
+// of a token within new. This allows TokenArray implementations to provide a faster Diff avoiding the boxing of
+// tokens by At.
+func DiffFunc(old, new TokenArray, equal func(i, j int) bool) CompositeOp {
+	n, m := old.Len(), new.Len()
+
+	var prefix int
+	for prefix < n && prefix < m && equal(prefix, prefix) {
+		prefix++
+	}
+
+	var suffix int
+	for suffix < n-prefix && suffix < m-prefix && equal(n-suffix-1, m-suffix-1) {
+		suffix++
+	}
+
+	b := diffBuilder{new: new}
+	b.match(0, 0, prefix)
+	myers(n-prefix-suffix, m-prefix-suffix, func(i, j int) bool {
+		return equal(prefix+i, prefix+j)
+	}, func(x, y, l int) {
+		b.match(prefix+x, prefix+y, l)
+	})
+	b.match(n-suffix, m-suffix, suffix)
+
+	return NewCompositeOp(b.ops...)
+}
+
+// diffBuilder builds an operation out of a sequence of matching ranges of old and new.
+type diffBuilder struct {
+	new  TokenArray
+	ops  []PrimitiveOp
+	i, j int
+}
+
+// match registers a matching range of length l starting at x in old and at y in new. Tokens between the end of the
+// previous match and the start of this one are deleted from old and inserted from new.
+func (b *diffBuilder) match(x, y, l int) {
+	if y > b.j {
+		b.ops = append(b.ops, Insert{Tokens: b.new.Slice(b.j, y)})
+	}
+	if x > b.i {
+		b.ops = append(b.ops, Delete{Count: x - b.i})
+	}
+	if l > 0 {
+		b.ops = append(b.ops, Retain{Count: l})
+	}
+	b.i, b.j = x+l, y+l
+}
+
+// myers finds the longest common subsequence of two sequences of lengths n and m using the linear space variant of
+// Myers' algorithm, calling match with each of its ranges in order.
+func myers(n, m int, equal func(i, j int) bool, match func(x, y, l int)) {
+	if n == 0 || m == 0 {
+		return
+	}
+
+	size := (n+m+1)/2 + 1
+	d := myersDiff{
+		equal:    equal,
+		match:    match,
+		forward:  make([]int, 2*size+1),
+		backward: make([]int, 2*size+1),
+	}
+	d.diff(0, n, 0, m)
+}
+
+type myersDiff struct {
+	equal func(i, j int) bool
+	match func(x, y, l int)
+	// forward and backward hold the furthest reaching paths of each diagonal, searching from the start and from the end.
+	forward, backward []int
+}
+
+// diff matches the tokens of old[x0:x1] and new[y0:y1], recursively splitting them at the middle snake of an optimal
+// path.
+func (d *myersDiff) diff(x0, x1, y0, y1 int) {
+	var prefix int
+	for x0+prefix < x1 && y0+prefix < y1 && d.equal(x0+prefix, y0+prefix) {
+		prefix++
+	}
+	if prefix > 0 {
+		d.match(x0, y0, prefix)
+		x0, y0 = x0+prefix, y0+prefix
+	}
+
+	var suffix int
+	for x0 < x1-suffix && y0 < y1-suffix && d.equal(x1-suffix-1, y1-suffix-1) {
+		suffix++
+	}
+	x1, y1 = x1-suffix, y1-suffix
+
+	// As the first and last tokens differ, at least two edits are needed if both ranges are not empty, so the middle
+	// snake splits them into strictly smaller problems.
+	if x0 < x1 && y0 < y1 {
+		x, y, u, v := d.middleSnake(x0, x1, y0, y1)
+		d.diff(x0, x, y0, y)
+		if u > x {
+			d.match(x, y, u-x)
+		}
+		d.diff(u, x1, v, y1)
+	}
+
+	if suffix > 0 {
+		d.match(x1, y1, suffix)
+	}
+}
+
+// middleSnake finds the snake in the middle of an optimal path between (x0, y0) and (x1, y1), searching from both ends
+// at once. The snake starts at (x, y) and ends at (u, v).
+func (d *myersDiff) middleSnake(x0, x1, y0, y1 int) (x, y, u, v int) {
+	n, m := x1-x0, y1-y0
+	delta := n - m
+	offset := len(d.forward) / 2
+	forward, backward := d.forward, d.backward
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	for step := 0; step <= (n+m+1)/2; step++ {
+		for k := -step; k <= step; k += 2 {
+			var x int
+			if k == -step || (k != step && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			startX := x
+			for x < n && x-k < m && d.equal(x0+x, y0+x-k) {
+				x++
+			}
+			forward[offset+k] = x
+
+			// the backward search has taken step-1 steps on diagonal delta-k
+			if delta%2 != 0 && delta-k >= -(step-1) && delta-k <= step-1 && x+backward[offset+delta-k] >= n {
+				return x0 + startX, y0 + startX - k, x0 + x, y0 + x - k
+			}
+		}
+
+		for k := -step; k <= step; k += 2 {
+			var x int
+			if k == -step || (k != step && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			startX := x
+			for x < n && x-k < m && d.equal(x1-x-1, y1-(x-k)-1) {
+				x++
+			}
+			backward[offset+k] = x
+
+			if delta%2 == 0 && delta-k >= -step && delta-k <= step && x+forward[offset+delta-k] >= n {
+				return x1 - x, y1 - (x - k), x1 - startX, y1 - (startX - k)
+			}
+		}
+	}
+	panic("myers: no middle snake found")
+}
@@ -0,0 +1,141 @@
+package gollab_test
+
+import (
+	"fmt"
+	"github.com/danielslee/gollab/runetoken"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/richtoken"
+)
+
+func testDiff(t *testing.T, old, new string) gollab.CompositeOp {
+	for _, op := range []gollab.CompositeOp{
+		runetoken.Diff(old, new),
+		gollab.Diff(runetoken.Array(old), runetoken.Array(new)),
+	} {
+		if err := op.Validate(len([]rune(old))); err != nil {
+			t.Errorf("invalid op %v: %v", op, err)
+			return nil
+		}
+
+		applied, err := runetoken.ApplyToString(op, old)
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		if applied != new {
+			t.Errorf("applied(%s) != new(%s), op: %v", applied, new, op)
+		}
+	}
+	return runetoken.Diff(old, new)
+}
+
+func TestDiff(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			old := randString(l)
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+			new, err := runetoken.ApplyToString(op, old)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testDiff(t, old, new)
+			testDiff(t, old, randString(rand.Intn(20)))
+		})
+	}
+}
+
+func TestDiffMinimal(t *testing.T) {
+	for _, test := range []struct {
+		old, new string
+		edits    int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"hello", "hello", 0},
+		{"abcabba", "cbabac", 5},
+		{"hello world", "hello, brave world", 7},
+		{"the quick brown fox", "the slow brown dog", 13},
+	} {
+		op := testDiff(t, test.old, test.new)
+
+		var edits int
+		for _, p := range op {
+			switch p.(type) {
+			case gollab.Insert, gollab.Delete:
+				edits += p.InputLength() + p.OutputLength()
+			}
+		}
+		if edits != test.edits {
+			t.Errorf("diff(%s, %s): expected %d edits, got %d (%v)", test.old, test.new, test.edits, edits, op)
+		}
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b.
+func lcsLength(a, b []rune) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else if prev[j+1] > cur[j] {
+				cur[j+1] = prev[j+1]
+			} else {
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func TestDiffMinimalRandom(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		old := []rune(randString(rand.Intn(30)))
+		new := []rune(randString(rand.Intn(30)))
+		for j := range new {
+			if rand.Intn(2) == 0 && j < len(old) {
+				new[j] = old[j]
+			}
+		}
+		op := testDiff(t, string(old), string(new))
+
+		var retained int
+		for _, p := range op {
+			if r, ok := p.(gollab.Retain); ok {
+				retained += r.Count
+			}
+		}
+		if expected := lcsLength(old, new); retained != expected {
+			t.Fatalf("diff(%s, %s): expected %d retained tokens, got %d (%v)", string(old), string(new), expected,
+				retained, op)
+		}
+	}
+}
+
+func TestDiffAttributedTokens(t *testing.T) {
+	bold := gollab.Attributes{"bold": true}
+	old := richtoken.New("hello", nil)
+	format := gollab.NewCompositeOp(gollab.Retain{Count: 1, Attributes: bold}, gollab.Retain{Count: 4})
+	applied, err := format.ApplyToTokenArray(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op := gollab.Diff(old, applied)
+	expected := gollab.NewCompositeOp(
+		gollab.Insert{Tokens: applied.Slice(0, 1)},
+		gollab.Delete{Count: 1},
+		gollab.Retain{Count: 4},
+	)
+	if !reflect.DeepEqual(op, expected) {
+		t.Errorf("expected %v, got %v", expected, op)
+	}
+}
@@ -47,7 +47,15 @@ applied in order into one. For example, we may want to merge `Insert("H")`, `Ret
 
 Instead of being hard-coded to only operate on plain text, Gollab operates on a string of abstract `tokens`.
 These tokens can be anything. Gollab includes an implementation of this for a string of runes (unicode codepoints) in
-the runetoken package.
+the runetoken package, and a rope of runes better suited for large documents in the ropetoken package. The
+graphemetoken and linetoken packages operate on grapheme clusters and whole lines instead of runes, and the bytetoken
+package on raw bytes.
+
+Retain and Insert operations may carry formatting Attributes, such as bold or link, which are composed and transformed
+along with the operations. The richtoken package provides tokens storing their own attributes.
+
+Array provides a generic TokenArray for tokens of any type T, and TypedTokenArray together with ApplyTokens allow
+operating on tokens without boxing each of them into an interface{}.
 
 Check out the documentation for TokenReader, TokenWriter and TokenArray interfaces for more details on how to provide
 your own implementation for use cases requiring more than plain unicode text.
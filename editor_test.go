@@ -0,0 +1,181 @@
+package gollab_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/bytetoken"
+	"github.com/danielslee/gollab/graphemetoken"
+	"github.com/danielslee/gollab/linetoken"
+	"github.com/danielslee/gollab/richtoken"
+	"github.com/danielslee/gollab/ropetoken"
+	"github.com/danielslee/gollab/runetoken"
+)
+
+var (
+	_ gollab.TokenArrayEditor = runetoken.Array{}
+	_ gollab.TokenArrayEditor = ropetoken.Rope{}
+)
+
+// plainArray is a TokenArray of runes not implementing TokenArrayEditor.
+type plainArray struct {
+	array runetoken.Array
+}
+
+func (a plainArray) Type() gollab.TokenArrayType {
+	return runetoken.ArrayType{}
+}
+
+func (a plainArray) At(idx int) interface{} {
+	return a.array.At(idx)
+}
+
+func (a plainArray) Slice(start, end int) gollab.TokenArray {
+	return plainArray{array: a.array[start:end]}
+}
+
+func (a plainArray) Len() int {
+	return len(a.array)
+}
+
+func TestApplyToTokenArray(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+			base := randString(op.InputLength())
+
+			expected, err := runetoken.ApplyToString(op, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, doc := range []gollab.TokenArray{
+				runetoken.Array(base),
+				ropetoken.New(base),
+				plainArray{array: runetoken.Array(base)},
+			} {
+				applied, err := op.ApplyToTokenArray(doc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if s := fmt.Sprint(applied); s != expected {
+					t.Errorf("%T: expected %q, got %q", doc, expected, s)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyToTokenArrayLengthMismatch(t *testing.T) {
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 3})
+	if _, err := op.ApplyToTokenArray(runetoken.Array("ab")); err != gollab.ErrLengthMismatch {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
+
+func TestApplyToTokenArrayInvalid(t *testing.T) {
+	for _, op := range []gollab.CompositeOp{
+		{gollab.Retain{Count: 3}, gollab.Retain{Count: -1}, gollab.Delete{Count: 2}},
+		{gollab.Delete{Count: 0}, gollab.Retain{Count: 4}},
+		{gollab.Retain{Count: 4}, gollab.Insert{}},
+	} {
+		for _, doc := range []gollab.TokenArray{
+			runetoken.Array("abcd"),
+			ropetoken.New("abcd"),
+			plainArray{array: runetoken.Array("abcd")},
+		} {
+			var opErr *gollab.InvalidOpError
+			if _, err := op.ApplyToTokenArray(doc); !errors.As(err, &opErr) {
+				t.Errorf("%T: expected an InvalidOpError applying %v, got %v", doc, op, err)
+			}
+		}
+	}
+}
+
+func TestApplyOpInvalid(t *testing.T) {
+	for _, op := range []gollab.CompositeOp{
+		{gollab.Retain{Count: 3}, gollab.Delete{Count: -1}},
+		{gollab.Retain{Count: 3}, gollab.Retain{Count: -1}},
+		{gollab.Delete{Count: -1}, gollab.Retain{Count: 3}},
+	} {
+		for _, editor := range []gollab.TokenArrayEditor{
+			runetoken.Array("ab"),
+			ropetoken.New("ab"),
+			gollab.Array[int]{1, 2},
+			graphemetoken.New("ab"),
+			linetoken.New("a\nb\n"),
+			richtoken.New("ab", nil),
+			bytetoken.Array("ab"),
+		} {
+			if _, err := editor.ApplyOp(op); !errors.Is(err, gollab.ErrInvalidCount) {
+				t.Errorf("%T: expected ErrInvalidCount applying %v, got %v", editor, op, err)
+			}
+		}
+
+		for _, test := range []struct {
+			apply func(gollab.Op, string) (string, error)
+			text  string
+		}{
+			{runetoken.ApplyToString, "ab"},
+			{graphemetoken.ApplyToString, "ab"},
+			{linetoken.ApplyToString, "a\nb\n"},
+		} {
+			if _, err := test.apply(op, test.text); err == nil {
+				t.Errorf("expected an error applying %v to %q", op, test.text)
+			}
+		}
+	}
+}
+
+func benchmarkDocument(n int) string {
+	return strings.Repeat("lorem ipsum dolor sit amet ", n/27+1)[:n]
+}
+
+func benchmarkKeystroke(n int) gollab.CompositeOp {
+	return gollab.NewCompositeOp(
+		gollab.Retain{Count: n / 2},
+		gollab.Insert{Tokens: runetoken.Array("x")},
+		gollab.Retain{Count: n - n/2},
+	)
+}
+
+func BenchmarkApply(b *testing.B) {
+	for _, n := range []int{1 << 10, 1 << 16, 1 << 20} {
+		doc := benchmarkDocument(n)
+		op := benchmarkKeystroke(n)
+
+		b.Run(fmt.Sprintf("TokenReader/%d", n), func(b *testing.B) {
+			array := runetoken.Array(doc)
+			for i := 0; i < b.N; i++ {
+				builder := array.Type().NewBuilder()
+				if err := op.Apply(gollab.NewTokenArrayReader(array), builder); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Array/%d", n), func(b *testing.B) {
+			array := runetoken.Array(doc)
+			for i := 0; i < b.N; i++ {
+				if _, err := op.ApplyToTokenArray(array); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Rope/%d", n), func(b *testing.B) {
+			rope := ropetoken.New(doc)
+			for i := 0; i < b.N; i++ {
+				if _, err := op.ApplyToTokenArray(rope); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
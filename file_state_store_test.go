@@ -0,0 +1,190 @@
+package gollab_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/runetoken"
+	"github.com/danielslee/gollab/server"
+)
+
+func openFileStateStore(t *testing.T, dir string, snapshotInterval int) *server.FileStateStore {
+	t.Helper()
+	store, err := server.OpenFileStateStore(dir, runetoken.Array{}, snapshotInterval)
+	if err != nil {
+		t.Fatalf("OpenFileStateStore: %v", err)
+	}
+	return store
+}
+
+// appendToStateStore appends text to the end of the document held by the store, based on its current revision.
+func appendToStateStore(t *testing.T, store server.StateStore, text string) {
+	t.Helper()
+	document, revision, _ := store.Current()
+	op := gollab.NewCompositeOp(gollab.Insert{Tokens: runetoken.Array(text)})
+	if document.Len() > 0 {
+		op = gollab.NewCompositeOp(gollab.Retain{Count: document.Len()}, gollab.Insert{Tokens: runetoken.Array(text)})
+	}
+	if err := store.ApplyClient(server.OpMessage{AuthorID: "a", Op: op, Revision: revision}); err != nil {
+		t.Fatalf("ApplyClient: %v", err)
+	}
+	<-store.OperationStream()
+}
+
+func checkFileStateStore(t *testing.T, store *server.FileStateStore, expected string, expectedRevision int) {
+	t.Helper()
+	document, revision, _ := store.Current()
+	if string(document.(runetoken.Array)) != expected || revision != expectedRevision {
+		t.Errorf("expected %q at revision %d, got %q at revision %d", expected, expectedRevision, document,
+			revision)
+	}
+}
+
+func TestFileStateStoreReopen(t *testing.T) {
+	for _, snapshotInterval := range []int{0, 1, 3} {
+		dir := t.TempDir()
+		store := openFileStateStore(t, dir, snapshotInterval)
+		var expected string
+		for i := 0; i < 10; i++ {
+			appendToStateStore(t, store, string(rune('a'+i)))
+			expected += string(rune('a' + i))
+		}
+		if err := store.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		store = openFileStateStore(t, dir, snapshotInterval)
+		checkFileStateStore(t, store, expected, 10)
+
+		// Operations based on a revision before reopening are still transformed.
+		op := gollab.NewCompositeOp(gollab.Insert{Tokens: runetoken.Array("!")}, gollab.Retain{Count: 8})
+		if err := store.ApplyClient(server.OpMessage{Op: op, Revision: 8}); err != nil {
+			t.Fatalf("ApplyClient: %v", err)
+		}
+		<-store.OperationStream()
+		checkFileStateStore(t, store, "!"+expected, 11)
+		store.Close()
+	}
+}
+
+func TestFileStateStoreTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStateStore(t, dir, 0)
+	appendToStateStore(t, store, "hello")
+	appendToStateStore(t, store, " world")
+	store.Close()
+
+	// Simulate a crash while writing the last record.
+	path := filepath.Join(dir, "log")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	store = openFileStateStore(t, dir, 0)
+	checkFileStateStore(t, store, "hello", 1)
+	appendToStateStore(t, store, "!")
+	store.Close()
+
+	store = openFileStateStore(t, dir, 0)
+	checkFileStateStore(t, store, "hello!", 2)
+	store.Close()
+}
+
+func TestFileStateStoreCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStateStore(t, dir, 0)
+	appendToStateStore(t, store, "hello")
+	appendToStateStore(t, store, " world")
+	store.Close()
+
+	path := filepath.Join(dir, "log")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[10] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupting a record followed by a valid one cannot be explained by a crash.
+	if _, err := server.OpenFileStateStore(dir, runetoken.Array{}, 0); !errors.Is(err, server.ErrCorruptLog) {
+		t.Errorf("expected ErrCorruptLog, got %v", err)
+	}
+	if after, err := os.ReadFile(path); err != nil || !bytes.Equal(after, data) {
+		t.Errorf("expected the log to be left untouched, got %d bytes instead of %d", len(after), len(data))
+	}
+}
+
+func TestFileStateStoreCorruptLastRecord(t *testing.T) {
+	// A bad last record is torn by a crash while appending it, whether its bytes are garbled or never made it to disk.
+	for _, test := range []struct {
+		name    string
+		corrupt func(data []byte, last int)
+	}{
+		{"garbled", func(data []byte, last int) { data[len(data)-1] ^= 0xff }},
+		{"zeroed", func(data []byte, last int) {
+			for i := last; i < len(data); i++ {
+				data[i] = 0
+			}
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "log")
+			store := openFileStateStore(t, dir, 0)
+			appendToStateStore(t, store, "hello")
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			appendToStateStore(t, store, " world")
+			store.Close()
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.corrupt(data, int(info.Size()))
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			store = openFileStateStore(t, dir, 0)
+			checkFileStateStore(t, store, "hello", 1)
+			store.Close()
+			if after, err := os.Stat(path); err != nil || after.Size() != info.Size() {
+				t.Errorf("expected the log to be truncated to %d bytes", info.Size())
+			}
+		})
+	}
+}
+
+func TestFileStateStoreTornHeader(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStateStore(t, dir, 0)
+	appendToStateStore(t, store, "hello")
+	store.Close()
+
+	// A header claiming a huge payload at the end of the log is a torn record, not an allocation.
+	f, err := os.OpenFile(filepath.Join(dir, "log"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	store = openFileStateStore(t, dir, 0)
+	checkFileStateStore(t, store, "hello", 1)
+	store.Close()
+}
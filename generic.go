@@ -0,0 +1,201 @@
+package gollab
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrUnexpectedTokenType is returned when a token isn't of the type required by a TypedTokenArray or ApplyTokens.
+var ErrUnexpectedTokenType = errors.New("unexpected token type")
+
+// TypedTokenArray is a TokenArray whose tokens are all of type T. Unlike At, its methods give access to the tokens
+// without boxing each of them into an interface{}.
+type TypedTokenArray[T any] interface {
+	TokenArray
+	// TokenAt returns a token given its index.
+	TokenAt(idx int) T
+	// AppendTokens appends the tokens between start and end to dst and returns the extended slice.
+	AppendTokens(dst []T, start, end int) []T
+}
+
+// TypedTokenArrayBuilder is a TokenArrayBuilder able to write tokens of type T without boxing them.
+type TypedTokenArrayBuilder[T any] interface {
+	TokenArrayBuilder
+	Write(token T) error
+}
+
+// Array is a TokenArray of tokens of type T, giving a type-safe TokenArray implementation for any token type.
+//
+// Arrays are encoded to JSON as JSON arrays of their tokens.
+type Array[T any] []T
+
+// NewInsert creates an Insert operation inserting the given tokens as an Array.
+func NewInsert[T any](tokens ...T) Insert {
+	return Insert{Tokens: Array[T](tokens)}
+}
+
+// Type returns the ArrayType.
+func (Array[T]) Type() TokenArrayType {
+	return ArrayType[T]{}
+}
+
+// At returns an element given its index.
+func (a Array[T]) At(idx int) interface{} {
+	return a[idx]
+}
+
+// TokenAt returns an element given its index.
+func (a Array[T]) TokenAt(idx int) T {
+	return a[idx]
+}
+
+// AppendTokens appends the tokens between start and end to dst.
+func (a Array[T]) AppendTokens(dst []T, start, end int) []T {
+	return append(dst, a[start:end]...)
+}
+
+// Slice slices the array.
+func (a Array[T]) Slice(start, end int) TokenArray {
+	return a[start:end]
+}
+
+// Len returns the length of the array.
+func (a Array[T]) Len() int {
+	return len(a)
+}
+
+// ApplyOp applies an operation to the array using ApplyTokens. It implements TokenArrayEditor.
+func (a Array[T]) ApplyOp(op CompositeOp) (TokenArray, error) {
+	res, err := ApplyTokens(op, a)
+	if err != nil {
+		return nil, err
+	}
+	return Array[T](res), nil
+}
+
+// ArrayType contains methods related to Array.
+type ArrayType[T any] struct{}
+
+// NewBuilder creates a new ArrayBuilder.
+func (ArrayType[T]) NewBuilder() TokenArrayBuilder {
+	return &ArrayBuilder[T]{}
+}
+
+// Concat concatenates two TokenArrays holding tokens of type T and returns the result.
+func (ArrayType[T]) Concat(a, b TokenArray) TokenArray {
+	res, err := appendTokens[T](make([]T, 0, a.Len()+b.Len()), a)
+	if err != nil {
+		panic("ArrayType.Concat: expected param a to contain tokens of type T")
+	}
+	res, err = appendTokens[T](res, b)
+	if err != nil {
+		panic("ArrayType.Concat: expected param b to contain tokens of type T")
+	}
+	return Array[T](res)
+}
+
+// UnmarshalTokenArray decodes an Array from a JSON array. It implements TokenArrayUnmarshaler.
+func (ArrayType[T]) UnmarshalTokenArray(data []byte) (TokenArray, error) {
+	var a Array[T]
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ArrayBuilder is a TokenArrayBuilder implementation using Array.
+type ArrayBuilder[T any] struct {
+	Array Array[T]
+}
+
+// Write appends a given token to the builder's Array.
+func (b *ArrayBuilder[T]) Write(token T) error {
+	b.Array = append(b.Array, token)
+	return nil
+}
+
+// WriteToken appends a given token, which has to be of type T, to the builder's Array.
+func (b *ArrayBuilder[T]) WriteToken(token interface{}) error {
+	t, ok := token.(T)
+	if !ok {
+		return ErrUnexpectedTokenType
+	}
+	return b.Write(t)
+}
+
+// TokenArray returns the Array.
+func (b *ArrayBuilder[T]) TokenArray() TokenArray {
+	return b.Array
+}
+
+// appendTokens appends all tokens of a TokenArray to dst, avoiding boxing if it is a TypedTokenArray.
+func appendTokens[T any](dst []T, t TokenArray) ([]T, error) {
+	if typed, ok := t.(TypedTokenArray[T]); ok {
+		return typed.AppendTokens(dst, 0, typed.Len()), nil
+	}
+
+	for i := 0; i < t.Len(); i++ {
+		token, ok := t.At(i).(T)
+		if !ok {
+			return nil, ErrUnexpectedTokenType
+		}
+		dst = append(dst, token)
+	}
+	return dst, nil
+}
+
+// ApplyTokens applies the operation to a slice of tokens, returning a new slice holding the result.
+//
+// Retained tokens are copied in bulk and inserted tokens are appended using TypedTokenArray.AppendTokens when
+// available, so no token is boxed into an interface{}, unless it has to be formatted with the attributes of the
+// operation. The operation is checked using CompositeOp.ValidateApply first. ErrUnexpectedTokenType is returned if an
+// insert operation contains tokens not of type T.
+func ApplyTokens[T any](op CompositeOp, tokens []T) ([]T, error) {
+	if err := op.ValidateApply(len(tokens)); err != nil {
+		return nil, err
+	}
+
+	res := make([]T, 0, op.OutputLength())
+	var pos int
+	for _, p := range op {
+		switch p := p.(type) {
+		case Retain:
+			if pos+p.Count > len(tokens) {
+				return nil, ErrLengthMismatch
+			}
+			start := len(res)
+			res = append(res, tokens[pos:pos+p.Count]...)
+			pos += p.Count
+			if err := formatTokens(res[start:], p.Attributes); err != nil {
+				return nil, err
+			}
+		case Delete:
+			pos += p.Count
+		case Insert:
+			start := len(res)
+			var err error
+			if res, err = appendTokens[T](res, p.Tokens); err != nil {
+				return nil, err
+			}
+			if err := formatTokens(res[start:], p.Attributes); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// formatTokens formats tokens in place with the given attributes if they are AttributedTokens.
+func formatTokens[T any](tokens []T, attributes Attributes) error {
+	if len(attributes) == 0 {
+		return nil
+	}
+	for i, token := range tokens {
+		formatted, ok := formatToken(token, attributes).(T)
+		if !ok {
+			return ErrUnexpectedTokenType
+		}
+		tokens[i] = formatted
+	}
+	return nil
+}
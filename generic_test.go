@@ -0,0 +1,129 @@
+package gollab_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/ropetoken"
+	"github.com/danielslee/gollab/runetoken"
+)
+
+var (
+	_ gollab.TypedTokenArray[rune]        = runetoken.Array{}
+	_ gollab.TypedTokenArray[rune]        = ropetoken.Rope{}
+	_ gollab.TypedTokenArray[int]         = gollab.Array[int]{}
+	_ gollab.TypedTokenArrayBuilder[rune] = &runetoken.ArrayBuilder{}
+	_ gollab.TypedTokenArrayBuilder[rune] = &ropetoken.RopeBuilder{}
+	_ gollab.TypedTokenArrayBuilder[int]  = &gollab.ArrayBuilder[int]{}
+)
+
+func TestApplyTokens(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+			base := randString(op.InputLength())
+
+			expected, err := runetoken.ApplyToString(op, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			applied, err := gollab.ApplyTokens(op, []rune(base))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(applied) != expected {
+				t.Errorf("expected %q, got %q", expected, string(applied))
+			}
+		})
+	}
+}
+
+func TestGenericArray(t *testing.T) {
+	type cell struct {
+		Value int `json:"value"`
+	}
+
+	doc := gollab.Array[cell]{{1}, {2}, {3}}
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 1},
+		gollab.NewInsert(cell{4}, cell{5}),
+		gollab.Delete{Count: 1},
+		gollab.Retain{Count: 1},
+	)
+
+	applied, err := op.ApplyToTokenArray(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (gollab.Array[cell]{{1}, {4}, {5}, {3}}); !reflect.DeepEqual(applied, expected) {
+		t.Errorf("expected %v, got %v", expected, applied)
+	}
+
+	builder := doc.Type().NewBuilder()
+	if err := op.Apply(gollab.NewTokenArrayReader(doc), builder); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(builder.TokenArray(), applied) {
+		t.Errorf("expected %v, got %v", applied, builder.TokenArray())
+	}
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[{"type":"retain","count":1},{"type":"insert","tokens":[{"value":4},{"value":5}]},` +
+		`{"type":"delete","count":1},{"type":"retain","count":1}]`; string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+	decoded, err := gollab.UnmarshalCompositeOp(data, gollab.ArrayType[cell]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, op) {
+		t.Errorf("expected %v, got %v", op, decoded)
+	}
+}
+
+func TestApplyTokensUnexpectedTokenType(t *testing.T) {
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 1}, gollab.NewInsert(1, 2))
+	if _, err := gollab.ApplyTokens(op, []rune("a")); err != gollab.ErrUnexpectedTokenType {
+		t.Errorf("expected ErrUnexpectedTokenType, got %v", err)
+	}
+	if err := (gollab.ArrayType[rune]{}).NewBuilder().WriteToken("a"); err != gollab.ErrUnexpectedTokenType {
+		t.Errorf("expected ErrUnexpectedTokenType, got %v", err)
+	}
+}
+
+func TestApplyTokensInvalidCount(t *testing.T) {
+	for _, op := range []gollab.CompositeOp{
+		{gollab.Retain{Count: 3}, gollab.Retain{Count: -1}},
+		{gollab.Retain{Count: 5}, gollab.Delete{Count: -3}},
+		{gollab.Delete{Count: 0}, gollab.Retain{Count: 2}},
+	} {
+		tokens := make([]int, 2, 10)
+		if _, err := gollab.ApplyTokens(op, tokens); !errors.Is(err, gollab.ErrInvalidCount) {
+			t.Errorf("%v: expected ErrInvalidCount, got %v", op, err)
+		}
+	}
+
+	// Normalization removes operations of zero tokens.
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 0}, gollab.NewInsert(3), gollab.Retain{Count: 2},
+		gollab.Delete{Count: 0})
+	if applied, err := gollab.ApplyTokens(op, []int{1, 2}); err != nil || !reflect.DeepEqual(applied, []int{3, 1, 2}) {
+		t.Errorf("expected [3 1 2], got %v (%v)", applied, err)
+	}
+
+	// Operations defined outside of gollab aren't validated, but retained tokens still have to lie within the slice.
+	op = gollab.CompositeOp{markOp{count: -3}, gollab.Retain{Count: 5}}
+	if _, err := gollab.ApplyTokens(op, make([]int, 2, 10)); err != gollab.ErrLengthMismatch {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/danielslee/gollab
 
-go 1.17
+go 1.18
@@ -0,0 +1,87 @@
+package gollab_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/graphemetoken"
+)
+
+func TestGraphemeSplit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"abc", []string{"a", "b", "c"}},
+		{"éa", []string{"é", "a"}},
+		{"\r\n\n", []string{"\r\n", "\n"}},
+		{"🇨🇿🇸🇰🇺", []string{"🇨🇿", "🇸🇰", "🇺"}},
+		{"👩‍👩‍👧x", []string{"👩‍👩‍👧", "x"}},
+		{"👍🏽👍", []string{"👍🏽", "👍"}},
+		{"a‍b", []string{"a‍", "b"}},
+		{"❤️!", []string{"❤️", "!"}},
+		{"한국어", []string{"한", "국", "어"}},
+		{"각", []string{"각"}},
+		{"कि", []string{"कि"}},
+		{"́", []string{"́"}},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		if clusters := graphemetoken.Split(test.input); !reflect.DeepEqual(clusters, test.expected) {
+			t.Errorf("Split(%q): expected %q, got %q", test.input, test.expected, clusters)
+		}
+	}
+}
+
+func TestGraphemeApplyToString(t *testing.T) {
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 2},
+		gollab.Delete{Count: 1},
+		gollab.Insert{Tokens: graphemetoken.New("🇸🇰")},
+		gollab.Retain{Count: 1},
+	)
+
+	applied, err := graphemetoken.ApplyToString(op, "aé🇨🇿!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "aé🇸🇰!"; applied != expected {
+		t.Errorf("expected %q, got %q", expected, applied)
+	}
+
+	if _, err := graphemetoken.ApplyToString(op, "aé!"); err != gollab.ErrLengthMismatch {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
+
+func TestGraphemeJSON(t *testing.T) {
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 1},
+		gollab.Insert{Tokens: graphemetoken.Array{"e", "́"}},
+	)
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := gollab.UnmarshalCompositeOp(data, graphemetoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, op) {
+		t.Errorf("expected %v, got %v", op, decoded)
+	}
+
+	decoded, err = gollab.UnmarshalCompositeOp([]byte(`[{"type":"insert","tokens":"🇨🇿é"}]`),
+		graphemetoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := gollab.NewCompositeOp(gollab.Insert{Tokens: graphemetoken.Array{"🇨🇿", "é"}}); !reflect.DeepEqual(
+		decoded, expected) {
+		t.Errorf("expected %v, got %v", expected, decoded)
+	}
+}
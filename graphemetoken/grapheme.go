@@ -0,0 +1,165 @@
+/*
+Package graphemetoken provides an implementation of the gollab.TokenArray for unicode strings whose tokens are extended
+grapheme clusters, as defined by Unicode Standard Annex #29, instead of runes.
+
+A grapheme cluster is what a user perceives as a single character, so an operation counting grapheme clusters can never
+split a flag emoji, an emoji ZWJ sequence or a letter followed by combining accents.
+
+Segmentation is implemented in this package without any external dependencies. It follows the UAX #29 rules, except
+for the Prepend property, using the unicode package and an approximation of the Extended_Pictographic property.
+*/
+package graphemetoken
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/danielslee/gollab"
+)
+
+// Array implements the TokenArray interface as a slice of grapheme clusters.
+//
+// Arrays are encoded to JSON as arrays of strings, one per grapheme cluster, so that the number of tokens is preserved
+// even when the concatenation of the clusters would be segmented differently.
+type Array []string
+
+// New splits a string into grapheme clusters, returning them as an Array.
+func New(s string) Array {
+	return Split(s)
+}
+
+// Type returns the ArrayType.
+func (Array) Type() gollab.TokenArrayType {
+	return ArrayType{}
+}
+
+// At returns an element given its index.
+func (t Array) At(idx int) interface{} {
+	return t[idx]
+}
+
+// TokenAt returns an element given its index. It implements gollab.TypedTokenArray.
+func (t Array) TokenAt(idx int) string {
+	return t[idx]
+}
+
+// AppendTokens appends the grapheme clusters between start and end to dst. It implements gollab.TypedTokenArray.
+func (t Array) AppendTokens(dst []string, start, end int) []string {
+	return append(dst, t[start:end]...)
+}
+
+// Slice slices the array.
+func (t Array) Slice(start, end int) gollab.TokenArray {
+	return t[start:end]
+}
+
+// Len returns the length of the array.
+func (t Array) Len() int {
+	return len(t)
+}
+
+// String returns the Array as a string.
+func (t Array) String() string {
+	return strings.Join(t, "")
+}
+
+// ApplyOp applies an operation to the array using gollab.ApplyTokens, returning the resulting Array. It implements
+// gollab.TokenArrayEditor.
+func (t Array) ApplyOp(op gollab.CompositeOp) (gollab.TokenArray, error) {
+	res, err := gollab.ApplyTokens(op, []string(t))
+	if err != nil {
+		return nil, err
+	}
+	return Array(res), nil
+}
+
+// ArrayType contains methods related to Array.
+type ArrayType struct{}
+
+// NewBuilder creates a new ArrayBuilder.
+func (ArrayType) NewBuilder() gollab.TokenArrayBuilder {
+	return &ArrayBuilder{
+		Array: []string{},
+	}
+}
+
+// Concat concatenates two TokenArrays and returns the result.
+func (ArrayType) Concat(a, b gollab.TokenArray) gollab.TokenArray {
+	ga, ok := a.(Array)
+	if !ok {
+		panic("ArrayType.Concat: expected param a to be of type Array")
+	}
+	gb, ok := b.(Array)
+	if !ok {
+		panic("ArrayType.Concat: expected param b to be of type Array")
+	}
+
+	newTokens := make(Array, len(ga)+len(gb))
+	copy(newTokens, ga)
+	copy(newTokens[len(ga):], gb)
+	return newTokens
+}
+
+// UnmarshalTokenArray decodes an Array from a JSON array of grapheme clusters. A plain JSON string is accepted too, in
+// which case it is split into grapheme clusters. It implements gollab.TokenArrayUnmarshaler.
+func (ArrayType) UnmarshalTokenArray(data []byte) (gollab.TokenArray, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return New(s), nil
+	}
+
+	var t Array
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ArrayBuilder is a TokenArrayBuilder implementation using Array.
+type ArrayBuilder struct {
+	Array Array
+}
+
+// Write appends a given grapheme cluster to the builder's Array. It implements gollab.TypedTokenArrayBuilder.
+func (b *ArrayBuilder) Write(cluster string) error {
+	b.Array = append(b.Array, cluster)
+	return nil
+}
+
+// WriteToken appends a given token to the builder's Array.
+func (b *ArrayBuilder) WriteToken(token interface{}) error {
+	s, ok := token.(string)
+	if !ok {
+		return errors.New("ArrayBuilder.WriteToken: expected a string")
+	}
+	return b.Write(s)
+}
+
+// TokenArray returns the Array.
+func (b ArrayBuilder) TokenArray() gollab.TokenArray {
+	return b.Array
+}
+
+// ApplyToString applies the operation to a plain Go string split into grapheme clusters, returning the result as a
+// plain Go string. This assumes the operation consumes and outputs grapheme clusters only.
+func ApplyToString(op gollab.Op, text string) (string, error) {
+	t := New(text)
+	if op.InputLength() != len(t) {
+		return "", gollab.ErrLengthMismatch
+	}
+
+	if c, ok := op.(gollab.CompositeOp); ok {
+		applied, err := t.ApplyOp(c)
+		if err != nil {
+			return "", err
+		}
+		return applied.(Array).String(), nil
+	}
+
+	var out ArrayBuilder
+	if err := op.Apply(gollab.NewTokenArrayReader(t), &out); err != nil {
+		return "", err
+	}
+	return out.Array.String(), nil
+}
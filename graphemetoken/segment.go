@@ -0,0 +1,203 @@
+package graphemetoken
+
+import "unicode"
+
+// property is the Grapheme_Cluster_Break property of a rune as defined by Unicode Standard Annex #29.
+type property int
+
+const (
+	propertyOther property = iota
+	propertyCR
+	propertyLF
+	propertyControl
+	propertyExtend
+	propertyZWJ
+	propertyRegionalIndicator
+	propertySpacingMark
+	propertyL
+	propertyV
+	propertyT
+	propertyLV
+	propertyLVT
+	propertyExtendedPictographic
+)
+
+// extendedPictographic approximates the Extended_Pictographic property, which isn't provided by the unicode package.
+var extendedPictographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00a9, Hi: 0x00a9, Stride: 1},
+		{Lo: 0x00ae, Hi: 0x00ae, Stride: 1},
+		{Lo: 0x203c, Hi: 0x203c, Stride: 1},
+		{Lo: 0x2049, Hi: 0x2049, Stride: 1},
+		{Lo: 0x2122, Hi: 0x2122, Stride: 1},
+		{Lo: 0x2139, Hi: 0x2139, Stride: 1},
+		{Lo: 0x2194, Hi: 0x2199, Stride: 1},
+		{Lo: 0x21a9, Hi: 0x21aa, Stride: 1},
+		{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+		{Lo: 0x2328, Hi: 0x2328, Stride: 1},
+		{Lo: 0x2388, Hi: 0x2388, Stride: 1},
+		{Lo: 0x23cf, Hi: 0x23cf, Stride: 1},
+		{Lo: 0x23e9, Hi: 0x23f3, Stride: 1},
+		{Lo: 0x23f8, Hi: 0x23fa, Stride: 1},
+		{Lo: 0x24c2, Hi: 0x24c2, Stride: 1},
+		{Lo: 0x25aa, Hi: 0x25ab, Stride: 1},
+		{Lo: 0x25b6, Hi: 0x25b6, Stride: 1},
+		{Lo: 0x25c0, Hi: 0x25c0, Stride: 1},
+		{Lo: 0x25fb, Hi: 0x25fe, Stride: 1},
+		{Lo: 0x2600, Hi: 0x2767, Stride: 1},
+		{Lo: 0x2794, Hi: 0x27bf, Stride: 1},
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1},
+		{Lo: 0x2b05, Hi: 0x2b07, Stride: 1},
+		{Lo: 0x2b1b, Hi: 0x2b1c, Stride: 1},
+		{Lo: 0x2b50, Hi: 0x2b50, Stride: 1},
+		{Lo: 0x2b55, Hi: 0x2b55, Stride: 1},
+		{Lo: 0x3030, Hi: 0x3030, Stride: 1},
+		{Lo: 0x303d, Hi: 0x303d, Stride: 1},
+		{Lo: 0x3297, Hi: 0x3297, Stride: 1},
+		{Lo: 0x3299, Hi: 0x3299, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1f0ff, Stride: 1},
+		{Lo: 0x1f10d, Hi: 0x1f10f, Stride: 1},
+		{Lo: 0x1f12f, Hi: 0x1f12f, Stride: 1},
+		{Lo: 0x1f16c, Hi: 0x1f171, Stride: 1},
+		{Lo: 0x1f17e, Hi: 0x1f17f, Stride: 1},
+		{Lo: 0x1f18e, Hi: 0x1f18e, Stride: 1},
+		{Lo: 0x1f191, Hi: 0x1f19a, Stride: 1},
+		{Lo: 0x1f1ad, Hi: 0x1f1e5, Stride: 1},
+		{Lo: 0x1f201, Hi: 0x1f20f, Stride: 1},
+		{Lo: 0x1f21a, Hi: 0x1f21a, Stride: 1},
+		{Lo: 0x1f22f, Hi: 0x1f22f, Stride: 1},
+		{Lo: 0x1f232, Hi: 0x1f23a, Stride: 1},
+		{Lo: 0x1f23c, Hi: 0x1f23f, Stride: 1},
+		{Lo: 0x1f249, Hi: 0x1f3fa, Stride: 1},
+		{Lo: 0x1f400, Hi: 0x1f53d, Stride: 1},
+		{Lo: 0x1f546, Hi: 0x1f64f, Stride: 1},
+		{Lo: 0x1f680, Hi: 0x1f6ff, Stride: 1},
+		{Lo: 0x1f774, Hi: 0x1f77f, Stride: 1},
+		{Lo: 0x1f7d5, Hi: 0x1f7ff, Stride: 1},
+		{Lo: 0x1f80c, Hi: 0x1f80f, Stride: 1},
+		{Lo: 0x1f848, Hi: 0x1f84f, Stride: 1},
+		{Lo: 0x1f85a, Hi: 0x1f85f, Stride: 1},
+		{Lo: 0x1f888, Hi: 0x1f88f, Stride: 1},
+		{Lo: 0x1f8ae, Hi: 0x1f8ff, Stride: 1},
+		{Lo: 0x1f90c, Hi: 0x1f93a, Stride: 1},
+		{Lo: 0x1f93c, Hi: 0x1f945, Stride: 1},
+		{Lo: 0x1f947, Hi: 0x1faff, Stride: 1},
+		{Lo: 0x1fc00, Hi: 0x1fffd, Stride: 1},
+	},
+}
+
+// propertyOf returns the Grapheme_Cluster_Break property of a rune.
+func propertyOf(r rune) property {
+	switch {
+	case r == '\r':
+		return propertyCR
+	case r == '\n':
+		return propertyLF
+	case r == 0x200d:
+		return propertyZWJ
+	case r == 0x200c, r >= 0x1f3fb && r <= 0x1f3ff, r >= 0xe0020 && r <= 0xe007f:
+		return propertyExtend
+	case r >= 0x1f1e6 && r <= 0x1f1ff:
+		return propertyRegionalIndicator
+	case r >= 0x1100 && r <= 0x115f, r >= 0xa960 && r <= 0xa97c:
+		return propertyL
+	case r >= 0x1160 && r <= 0x11a7, r >= 0xd7b0 && r <= 0xd7c6:
+		return propertyV
+	case r >= 0x11a8 && r <= 0x11ff, r >= 0xd7cb && r <= 0xd7fb:
+		return propertyT
+	case r >= 0xac00 && r <= 0xd7a3:
+		if (r-0xac00)%28 == 0 {
+			return propertyLV
+		}
+		return propertyLVT
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return propertyExtend
+	case unicode.Is(unicode.Mc, r):
+		return propertySpacingMark
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Zl, unicode.Zp):
+		return propertyControl
+	case unicode.Is(extendedPictographic, r):
+		return propertyExtendedPictographic
+	}
+	return propertyOther
+}
+
+// segmenter keeps the state needed to find grapheme cluster boundaries.
+type segmenter struct {
+	prev property
+	// regionalIndicators is the number of consecutive regional indicators ending with the previous rune.
+	regionalIndicators int
+	// pictographic is true when the previous runes are an extended pictographic character followed by any number of
+	// extending characters.
+	pictographic bool
+	// pictographicZWJ is true when the previous rune is a ZWJ following a pictographic sequence.
+	pictographicZWJ bool
+}
+
+// isBoundary reports whether there is a boundary between the previous rune and a rune with the given property,
+// following the rules of UAX #29. The Prepend property isn't supported.
+func (s *segmenter) isBoundary(p property) bool {
+	prev := s.prev
+	switch {
+	case prev == propertyCR && p == propertyLF:
+		return false
+	case prev == propertyCR, prev == propertyLF, prev == propertyControl:
+		return true
+	case p == propertyCR, p == propertyLF, p == propertyControl:
+		return true
+	case prev == propertyL && (p == propertyL || p == propertyV || p == propertyLV || p == propertyLVT):
+		return false
+	case (prev == propertyLV || prev == propertyV) && (p == propertyV || p == propertyT):
+		return false
+	case (prev == propertyLVT || prev == propertyT) && p == propertyT:
+		return false
+	case p == propertyExtend, p == propertyZWJ, p == propertySpacingMark:
+		return false
+	case prev == propertyZWJ && p == propertyExtendedPictographic && s.pictographicZWJ:
+		return false
+	case prev == propertyRegionalIndicator && p == propertyRegionalIndicator && s.regionalIndicators%2 == 1:
+		return false
+	}
+	return true
+}
+
+// advance updates the state after a rune with the given property.
+func (s *segmenter) advance(p property) {
+	if p == propertyRegionalIndicator {
+		s.regionalIndicators++
+	} else {
+		s.regionalIndicators = 0
+	}
+
+	s.pictographicZWJ = p == propertyZWJ && s.pictographic
+	switch p {
+	case propertyExtendedPictographic:
+		s.pictographic = true
+	case propertyExtend:
+	default:
+		s.pictographic = false
+	}
+
+	s.prev = p
+}
+
+// Split splits a string into extended grapheme clusters.
+func Split(s string) []string {
+	var clusters []string
+	var seg segmenter
+	start := 0
+	for i, r := range s {
+		p := propertyOf(r)
+		if i > 0 && seg.isBoundary(p) {
+			clusters = append(clusters, s[start:i])
+			start = i
+		}
+		seg.advance(p)
+	}
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+	return clusters
+}
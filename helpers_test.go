@@ -72,3 +72,33 @@ func testEquality(t *testing.T, op1, op2 gollab.Op) {
 		t.Errorf("op1(%s) != op2(%s)", op1Output, op2Output)
 	}
 }
+
+// markOp is a PrimitiveOp defined outside of gollab retaining count tokens. It implements neither gollab.Inverter nor
+// gollab.Validator.
+type markOp struct {
+	count int
+}
+
+func (m markOp) InputLength() int {
+	return m.count
+}
+
+func (m markOp) OutputLength() int {
+	return m.count
+}
+
+func (m markOp) Apply(reader gollab.TokenReader, writer gollab.TokenWriter) error {
+	return gollab.Retain{Count: m.count}.Apply(reader, writer)
+}
+
+func (m markOp) Slice(start, end int) gollab.PrimitiveOp {
+	return markOp{count: end - start}
+}
+
+func (m markOp) Compose(b gollab.PrimitiveOp) gollab.PrimitiveOp {
+	return m
+}
+
+func (m markOp) Transform(b gollab.PrimitiveOp) (aPrime, bPrime gollab.PrimitiveOp) {
+	return m, b
+}
@@ -0,0 +1,130 @@
+package gollab_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/runetoken"
+	"github.com/danielslee/gollab/server"
+)
+
+var (
+	_ server.HistoryStore = (*server.MemoryStateStore)(nil)
+	_ server.HistoryStore = (*server.FileStateStore)(nil)
+)
+
+// applyRandomOps applies random operations by alternating authors to the store, returning the document after each
+// revision.
+func applyRandomOps(t *testing.T, store server.StateStore, n int) []string {
+	t.Helper()
+	document, _, _ := store.Current()
+	documents := []string{string(document.(runetoken.Array))}
+	for i := 0; i < n; i++ {
+		document, revision, _ := store.Current()
+		op := randomCompositeOp(document.Len(), document.Len()/2+rand.Intn(10))
+		msg := server.OpMessage{AuthorID: fmt.Sprint(i % 2), Op: op, Revision: revision}
+		if err := store.ApplyClient(msg); err != nil {
+			t.Fatalf("ApplyClient: %v", err)
+		}
+		<-store.OperationStream()
+
+		document, _, _ = store.Current()
+		documents = append(documents, string(document.(runetoken.Array)))
+	}
+	return documents
+}
+
+// checkHistoryStore checks the history of the store against the documents at revisions starting from base.
+func checkHistoryStore(t *testing.T, store server.HistoryStore, base int, documents []string) {
+	t.Helper()
+	for i, expected := range documents {
+		revision := base + i
+		document, err := store.DocumentAt(revision)
+		if err != nil {
+			t.Fatalf("DocumentAt(%d): %v", revision, err)
+		}
+		if string(document.(runetoken.Array)) != expected {
+			t.Errorf("DocumentAt(%d): expected %q, got %q", revision, expected, document)
+		}
+	}
+
+	from := base + rand.Intn(len(documents))
+	to := from + rand.Intn(base+len(documents)-from)
+	msgs, err := store.OpsRange(from, to)
+	if err != nil {
+		t.Fatalf("OpsRange(%d, %d): %v", from, to, err)
+	}
+	if len(msgs) != to-from {
+		t.Fatalf("OpsRange(%d, %d): expected %d operations, got %d", from, to, to-from, len(msgs))
+	}
+
+	document := gollab.TokenArray(runetoken.Array(documents[from-base]))
+	for i, msg := range msgs {
+		if msg.Revision != from+i+1 || msg.AuthorID != fmt.Sprint((from+i)%2) {
+			t.Errorf("OpsRange(%d, %d): unexpected revision %d by %q at index %d", from, to, msg.Revision,
+				msg.AuthorID, i)
+		}
+		if document, err = msg.Op.ApplyToTokenArray(document); err != nil {
+			t.Fatalf("OpsRange(%d, %d): %v", from, to, err)
+		}
+	}
+	if string(document.(runetoken.Array)) != documents[to-base] {
+		t.Errorf("OpsRange(%d, %d): expected %q after applying, got %q", from, to, documents[to-base], document)
+	}
+}
+
+func TestMemoryStateStoreHistory(t *testing.T) {
+	store := server.NewMemoryStateStore(runetoken.Array("hello"))
+	documents := applyRandomOps(t, store, 50)
+	checkHistoryStore(t, store, 0, documents)
+
+	if _, err := store.DocumentAt(51); !errors.Is(err, server.ErrUnknownRevision) {
+		t.Errorf("expected ErrUnknownRevision, got %v", err)
+	}
+	if _, err := store.OpsRange(10, 5); !errors.Is(err, server.ErrUnknownRevision) {
+		t.Errorf("expected ErrUnknownRevision, got %v", err)
+	}
+
+	store.SetRetentionPolicy(server.RetentionPolicy{MaxRevisions: 10})
+	documents = append(documents, applyRandomOps(t, store, 1)[1:]...)
+	if _, err := store.DocumentAt(39); !errors.Is(err, server.ErrRevisionTooOld) {
+		t.Errorf("expected ErrRevisionTooOld, got %v", err)
+	}
+	if _, err := store.OpsRange(39, 45); !errors.Is(err, server.ErrRevisionTooOld) {
+		t.Errorf("expected ErrRevisionTooOld, got %v", err)
+	}
+	checkHistoryStore(t, store, 40, documents[40:])
+}
+
+func TestFileStateStoreHistory(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStateStore(t, dir, 7)
+	documents := applyRandomOps(t, store, 30)
+	checkHistoryStore(t, store, 0, documents)
+	store.Close()
+
+	store = openFileStateStore(t, dir, 7)
+	checkHistoryStore(t, store, 0, documents)
+	store.Close()
+}
+
+func TestHistoryStoreRestore(t *testing.T) {
+	store := server.NewMemoryStateStore(runetoken.Array("hello"))
+	documents := applyRandomOps(t, store, 10)
+
+	old, _ := store.DocumentAt(3)
+	current, revision, _ := store.Current()
+	msg := server.OpMessage{Op: gollab.Diff(current, old), Revision: revision}
+	if err := store.ApplyClient(msg); err != nil {
+		t.Fatalf("ApplyClient: %v", err)
+	}
+	<-store.OperationStream()
+
+	current, _, _ = store.Current()
+	if string(current.(runetoken.Array)) != documents[3] {
+		t.Errorf("expected %q after restoring, got %q", documents[3], current)
+	}
+}
@@ -0,0 +1,240 @@
+package gollab_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/runetoken"
+	"github.com/danielslee/gollab/server"
+)
+
+// closingStateStore is a MemoryStateStore counting how many times it has been closed.
+type closingStateStore struct {
+	*server.MemoryStateStore
+	closed *int
+	mux    *sync.Mutex
+}
+
+func (s closingStateStore) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	*s.closed++
+	return nil
+}
+
+// hubFactory counts the documents loaded and closed by a Hub.
+type hubFactory struct {
+	mux    sync.Mutex
+	loaded map[string]int
+	closed int
+}
+
+func (f *hubFactory) create(docID string) (server.StateStore, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if docID == "missing" {
+		return nil, errors.New("missing document")
+	}
+	f.loaded[docID]++
+	return closingStateStore{
+		MemoryStateStore: server.NewMemoryStateStore(runetoken.Array(docID)),
+		closed:           &f.closed,
+		mux:              &f.mux,
+	}, nil
+}
+
+func (f *hubFactory) counts() (loaded map[string]int, closed int) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	loaded = make(map[string]int)
+	for id, n := range f.loaded {
+		loaded[id] = n
+	}
+	return loaded, f.closed
+}
+
+func joinHub(t *testing.T, hub *server.Hub, docID string) (int, <-chan interface{}) {
+	t.Helper()
+	id, c, err := hub.Join(docID)
+	if err != nil {
+		t.Fatalf("Join(%q): %v", docID, err)
+	}
+	if msg, ok := (<-c).(server.InitMessage); !ok || string(msg.Document.(runetoken.Array)) != docID {
+		t.Fatalf("Join(%q): unexpected init message %v", docID, msg)
+	}
+	return id, c
+}
+
+// waitFor waits for a condition to become true, failing the test after a second.
+func waitFor(t *testing.T, condition func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitForDocuments(t *testing.T, hub *server.Hub, expected []string) {
+	t.Helper()
+	waitFor(t, func() bool {
+		return reflect.DeepEqual(hub.Documents(), expected)
+	}, "expected documents %v", expected)
+}
+
+func TestHub(t *testing.T) {
+	factory := &hubFactory{loaded: make(map[string]int)}
+	hub := server.NewHub(factory.create, 10*time.Millisecond)
+
+	a, aChan := joinHub(t, hub, "a")
+	b, bChan := joinHub(t, hub, "a")
+	_, otherChan := joinHub(t, hub, "b")
+	if loaded, _ := factory.counts(); !reflect.DeepEqual(loaded, map[string]int{"a": 1, "b": 1}) {
+		t.Errorf("expected each document to be loaded once, got %v", loaded)
+	}
+
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 1}, gollab.Insert{Tokens: runetoken.Array("!")})
+	msg := server.ClientMessage{ClientID: a, Message: server.OpMessage{AuthorID: "a", Op: op}}
+	if err := hub.Send("a", msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	for _, c := range []<-chan interface{}{aChan, bChan} {
+		if msg, ok := (<-c).(server.OpMessage); !ok || msg.Revision != 1 {
+			t.Errorf("expected the operation to be broadcast, got %v", msg)
+		}
+	}
+	select {
+	case msg := <-otherChan:
+		t.Errorf("expected no message on another document, got %v", msg)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	if err := hub.Send("c", msg); !errors.Is(err, server.ErrDocumentNotLoaded) {
+		t.Errorf("expected ErrDocumentNotLoaded, got %v", err)
+	}
+	if err := hub.Leave("a", 42); !errors.Is(err, server.ErrUnknownClient) {
+		t.Errorf("expected ErrUnknownClient, got %v", err)
+	}
+	if _, _, err := hub.Join("missing"); err == nil {
+		t.Errorf("expected the factory error")
+	}
+
+	// The document is unloaded once all of its clients leave.
+	if err := hub.Leave("a", a); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	waitForDocuments(t, hub, []string{"a", "b"})
+	if err := hub.Leave("a", b); err != nil {
+		t.Fatal(err)
+	}
+	waitForDocuments(t, hub, []string{"b"})
+	waitFor(t, func() bool {
+		_, closed := factory.counts()
+		return closed == 1
+	}, "expected the state store to be closed")
+
+	// Rejoining loads the document again.
+	joinHub(t, hub, "a")
+	if loaded, _ := factory.counts(); loaded["a"] != 2 {
+		t.Errorf("expected the document to be loaded again, got %v", loaded)
+	}
+
+	if err := hub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-otherChan; ok {
+		t.Errorf("expected client channels to be closed")
+	}
+	if _, closed := factory.counts(); closed != 3 {
+		t.Errorf("expected all state stores to be closed, got %d", closed)
+	}
+	if _, _, err := hub.Join("a"); !errors.Is(err, server.ErrHubClosed) {
+		t.Errorf("expected ErrHubClosed, got %v", err)
+	}
+}
+
+func TestHubFileStateStore(t *testing.T) {
+	dir := t.TempDir()
+	hub := server.NewHub(func(docID string) (server.StateStore, error) {
+		return server.OpenFileStateStore(filepath.Join(dir, docID), runetoken.Array{}, 0)
+	}, 0)
+
+	for i := 0; i < 10; i++ {
+		id, c, err := hub.Join("doc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		initMsg := (<-c).(server.InitMessage)
+		if initMsg.Revision != i {
+			t.Fatalf("expected revision %d after reloading, got %d", i, initMsg.Revision)
+		}
+
+		op := gollab.NewCompositeOp(gollab.Insert{Tokens: runetoken.Array("a")})
+		if i > 0 {
+			op = gollab.NewCompositeOp(gollab.Retain{Count: i}, gollab.Insert{Tokens: runetoken.Array("a")})
+		}
+		msg := server.OpMessage{Op: op, Revision: i}
+		if err := hub.Send("doc", server.ClientMessage{ClientID: id, Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+		<-c
+		if err := hub.Leave("doc", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := hub.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHubStuckClient(t *testing.T) {
+	factory := &hubFactory{loaded: make(map[string]int)}
+	hub := server.NewHub(factory.create, time.Hour)
+
+	_, stuckChan := joinHub(t, hub, "a")
+	id, c := joinHub(t, hub, "a")
+
+	// The stuck client never reads its messages, so it gets dropped once its channel is full.
+	for i := 0; i < 200; i++ {
+		op := gollab.NewCompositeOp(gollab.Retain{Count: 1 + i}, gollab.Insert{Tokens: runetoken.Array("!")})
+		msg := server.ClientMessage{ClientID: id, Message: server.OpMessage{AuthorID: "b", Op: op, Revision: i}}
+		if err := hub.Send("a", msg); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if msg, ok := (<-c).(server.OpMessage); !ok || msg.Revision != i+1 {
+			t.Fatalf("expected revision %d to be broadcast, got %v", i+1, msg)
+		}
+	}
+
+	var received int
+	for range stuckChan {
+		received++
+	}
+	if received == 0 || received >= 200 {
+		t.Errorf("expected the stuck client to be dropped after filling its channel, got %d messages", received)
+	}
+
+	closed := make(chan error)
+	go func() {
+		closed <- hub.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Close not to block")
+	}
+}
@@ -6,8 +6,13 @@ import (
 
 // Insert represents an insert operation which consumes nothing and writes the content of its Tokens TokenArray to the
 // output.
+//
+// If Attributes are set, the inserted tokens are formatted with them, a nil value removing the attribute from tokens
+// carrying their own attributes. See Attributes for more details, including why Insert values cannot be compared using
+// ==.
 type Insert struct {
-	Tokens TokenArray
+	Tokens     TokenArray
+	Attributes Attributes
 }
 
 // InputLength returns the required input length.
@@ -27,13 +32,13 @@ func (i Insert) OutputLength() int {
 // Slice slices the operation.
 func (i Insert) Slice(start, end int) PrimitiveOp {
 	checkSliceValidity(start, end)
-	return Insert{Tokens: i.Tokens.Slice(start, end)}
+	return Insert{Tokens: i.Tokens.Slice(start, end), Attributes: i.Attributes}
 }
 
 // Apply applies the operation.
 func (i Insert) Apply(_ TokenReader, writer TokenWriter) error {
 	for idx := 0; idx < i.Tokens.Len(); idx++ {
-		token := i.Tokens.At(idx)
+		token := formatToken(i.Tokens.At(idx), i.Attributes)
 		if err := writer.WriteToken(token); err != nil {
 			return err
 		}
@@ -42,9 +47,11 @@ func (i Insert) Apply(_ TokenReader, writer TokenWriter) error {
 }
 
 // Join joins the operation with another one if possible. This is used for normalization.
+//
+// Insert operations are only joined if their attributes are equal.
 func (i Insert) Join(next PrimitiveOp) PrimitiveOp {
-	if nextInsert, ok := next.(Insert); ok {
-		return Insert{Tokens: i.Tokens.Type().Concat(i.Tokens, nextInsert.Tokens)}
+	if nextInsert, ok := next.(Insert); ok && i.Attributes.Equal(nextInsert.Attributes) {
+		return Insert{Tokens: i.Tokens.Type().Concat(i.Tokens, nextInsert.Tokens), Attributes: i.Attributes}
 	}
 	return nil
 }
@@ -53,15 +60,25 @@ func (i Insert) Join(next PrimitiveOp) PrimitiveOp {
 //
 // The output length of the first operation has to equal the input length of the other one.
 func (i Insert) Compose(b PrimitiveOp) PrimitiveOp {
-	checkComposeLength(i, b)
+	c, err := i.tryCompose(b)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
 
-	switch b.(type) {
+func (i Insert) tryCompose(b PrimitiveOp) (PrimitiveOp, error) {
+	if err := checkComposeLength(i, b); err != nil {
+		return nil, err
+	}
+
+	switch b := b.(type) {
 	case Delete:
-		return NoOp{}
+		return NoOp{}, nil
 	case Retain:
-		return i
+		return Insert{Tokens: i.Tokens, Attributes: ComposeAttributes(i.Attributes, b.Attributes, true)}, nil
 	default:
-		panic(ErrUnexpectedOp)
+		return nil, ErrUnexpectedOp
 	}
 }
 
@@ -69,17 +86,50 @@ func (i Insert) Compose(b PrimitiveOp) PrimitiveOp {
 //
 // The input length of both operations has to be equal.
 func (i Insert) Transform(b PrimitiveOp) (aPrime, bPrime PrimitiveOp) {
-	checkTransformLength(i, b)
+	aPrime, bPrime, err := i.tryTransform(b)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (i Insert) tryTransform(b PrimitiveOp) (aPrime, bPrime PrimitiveOp, err error) {
+	if err := checkTransformLength(i, b); err != nil {
+		return nil, nil, err
+	}
 
 	switch b.(type) {
 	case NoOp:
-		return i, Retain{Count: i.OutputLength()}
+		return i, Retain{Count: i.OutputLength()}, nil
 	default:
-		panic(ErrUnexpectedOp)
+		return nil, nil, ErrUnexpectedOp
+	}
+}
+
+// Invert returns the inverse of the operation given the tokens it consumes.
+//
+// The inverse of an Insert operation is a Delete operation removing the inserted tokens.
+func (i Insert) Invert(input TokenArray) PrimitiveOp {
+	checkInvertLength(i, input)
+	return Delete{Count: i.OutputLength()}
+}
+
+// Validate checks whether the operation is valid, returning ErrNilTokens if it has no Tokens and ErrInvalidCount if
+// they are empty.
+func (i Insert) Validate() error {
+	if i.Tokens == nil {
+		return ErrNilTokens
+	}
+	if i.Tokens.Len() == 0 {
+		return ErrInvalidCount
 	}
+	return nil
 }
 
 // String provides a string representation of the Insert operation.
 func (i Insert) String() string {
+	if len(i.Attributes) > 0 {
+		return fmt.Sprintf("Insert(%s, %s)", i.Tokens, i.Attributes)
+	}
 	return fmt.Sprintf("Insert(%s)", i.Tokens)
 }
@@ -0,0 +1,89 @@
+/*
+Package binenc implements the varint and length-prefixed encoding shared by the binary forms of gollab's operations,
+the server's messages and its on-disk state.
+*/
+package binenc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Writer appends values to a buffer.
+type Writer struct {
+	bytes.Buffer
+}
+
+// WriteUvarint writes an unsigned varint.
+func (w *Writer) WriteUvarint(x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], x)])
+}
+
+// WriteVarint writes a signed varint.
+func (w *Writer) WriteVarint(x int64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutVarint(buf[:], x)])
+}
+
+// WriteBytes writes a byte slice prefixed by its length.
+func (w *Writer) WriteBytes(b []byte) {
+	w.WriteUvarint(uint64(len(b)))
+	w.Write(b)
+}
+
+// Reader reads values written by a Writer. Reading past the end of the data returns io.ErrUnexpectedEOF.
+type Reader struct {
+	*bytes.Reader
+}
+
+// NewReader creates a Reader reading the given data.
+func NewReader(data []byte) Reader {
+	return Reader{Reader: bytes.NewReader(data)}
+}
+
+// ReadUvarint reads an unsigned varint.
+func (r Reader) ReadUvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return x, err
+}
+
+// ReadVarint reads a signed varint.
+func (r Reader) ReadVarint() (int64, error) {
+	x, err := binary.ReadVarint(r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return x, err
+}
+
+// ReadInt reads a signed varint as an int.
+func (r Reader) ReadInt() (int, error) {
+	x, err := r.ReadVarint()
+	return int(x), err
+}
+
+// ReadBytes reads a length-prefixed byte slice.
+func (r Reader) ReadBytes() ([]byte, error) {
+	l, err := r.ReadUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := make([]byte, l)
+	_, err = io.ReadFull(r, b)
+	return b, err
+}
+
+// ReadString reads a length-prefixed string.
+func (r Reader) ReadString() (string, error) {
+	b, err := r.ReadBytes()
+	return string(b), err
+}
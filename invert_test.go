@@ -0,0 +1,95 @@
+package gollab_test
+
+import (
+	"fmt"
+	"github.com/danielslee/gollab/runetoken"
+	"math/rand"
+	"testing"
+
+	"github.com/danielslee/gollab"
+)
+
+var (
+	_ gollab.Inverter  = gollab.NoOp{}
+	_ gollab.Inverter  = gollab.Retain{}
+	_ gollab.Inverter  = gollab.Delete{}
+	_ gollab.Inverter  = gollab.Insert{}
+	_ gollab.Validator = gollab.NoOp{}
+	_ gollab.Validator = gollab.Retain{}
+	_ gollab.Validator = gollab.Delete{}
+	_ gollab.Validator = gollab.Insert{}
+)
+
+func testInvert(t *testing.T, input string, op gollab.CompositeOp) {
+	inverse, err := op.Invert(runetoken.Array(input))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	afterOp, err := runetoken.ApplyToString(op, input)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	afterInverse, err := runetoken.ApplyToString(inverse, afterOp)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if afterInverse != input {
+		t.Errorf("afterInverse(%s) != input(%s), op: %v, inverse: %v",
+			afterInverse, input, op, inverse)
+	}
+}
+
+func TestInvert(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			str := randString(l)
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+			testInvert(t, str, op)
+		})
+	}
+}
+
+func TestInvertLengthMismatch(t *testing.T) {
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 3}, gollab.Delete{Count: 2})
+	if _, err := op.Invert(runetoken.Array("hello!")); err != gollab.ErrLengthMismatch {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
+
+func TestInvertUnknownOp(t *testing.T) {
+	op := gollab.CompositeOp{markOp{count: 2}, gollab.Delete{Count: 1}}
+	if err := op.Validate(3); err != nil {
+		t.Errorf("expected an operation not implementing Validator to be valid, got %v", err)
+	}
+	if _, err := op.Invert(runetoken.Array("abc")); err != gollab.ErrUnexpectedOp {
+		t.Errorf("expected ErrUnexpectedOp, got %v", err)
+	}
+}
+
+func ExampleCompositeOp_Invert() {
+	op := gollab.NewCompositeOp(
+		gollab.Delete{Count: 1},
+		gollab.Insert{Tokens: runetoken.Array("H")},
+		gollab.Retain{Count: 4},
+		gollab.Insert{Tokens: runetoken.Array(", World")})
+
+	inverse, _ := op.Invert(runetoken.Array("hello"))
+	fmt.Println("inverse:", inverse)
+
+	afterOp, _ := runetoken.ApplyToString(op, "hello")
+	afterInverse, _ := runetoken.ApplyToString(inverse, afterOp)
+	fmt.Println("after op:", afterOp)
+	fmt.Println("after inverse:", afterInverse)
+	// Output:
+	// inverse: [Insert(h) Delete(1) Retain(4) Delete(7)]
+	// after op: Hello, World
+	// after inverse: hello
+}
@@ -6,14 +6,33 @@ import (
 )
 
 type jsonOp struct {
-	Type   string     `json:"type"`
-	Tokens TokenArray `json:"tokens,omitempty"`
-	Count  int        `json:"count,omitempty"`
+	Type       string     `json:"type"`
+	Tokens     TokenArray `json:"tokens,omitempty"`
+	Count      int        `json:"count,omitempty"`
+	Attributes Attributes `json:"attributes,omitempty"`
+}
+
+// rawJSONOp is used when decoding a jsonOp, deferring the decoding of its tokens until their type is known.
+type rawJSONOp struct {
+	Type       string          `json:"type"`
+	Tokens     json.RawMessage `json:"tokens,omitempty"`
+	Count      int             `json:"count,omitempty"`
+	Attributes Attributes      `json:"attributes,omitempty"`
 }
 
 // ErrUnknownOp represents an unknown operation error which can occur when parsing operations from JSON.
 var ErrUnknownOp = errors.New("unknown operation")
 
+// ErrNoTokenArrayType is returned when decoding an insert operation from JSON without a TokenArrayType implementing
+// TokenArrayUnmarshaler. Use UnmarshalCompositeOp to decode operations containing inserts.
+var ErrNoTokenArrayType = errors.New("no token array type to decode tokens with")
+
+// TokenArrayUnmarshaler is implemented by a TokenArrayType able to decode its TokenArrays from JSON. It is the hook used
+// by UnmarshalCompositeOp and UnmarshalTokenArray.
+type TokenArrayUnmarshaler interface {
+	UnmarshalTokenArray(data []byte) (TokenArray, error)
+}
+
 func newJSONOp(op PrimitiveOp) (jsonOp, error) {
 	switch op := op.(type) {
 	case NoOp:
@@ -22,8 +41,9 @@ func newJSONOp(op PrimitiveOp) (jsonOp, error) {
 		}, nil
 	case Retain:
 		return jsonOp{
-			Type:  "retain",
-			Count: op.Count,
+			Type:       "retain",
+			Count:      op.Count,
+			Attributes: op.Attributes,
 		}, nil
 	case Delete:
 		return jsonOp{
@@ -32,43 +52,73 @@ func newJSONOp(op PrimitiveOp) (jsonOp, error) {
 		}, nil
 	case Insert:
 		return jsonOp{
-			Type:   "insert",
-			Tokens: op.Tokens,
+			Type:       "insert",
+			Tokens:     op.Tokens,
+			Attributes: op.Attributes,
 		}, nil
 	}
 	return jsonOp{}, ErrUnknownOp
 }
 
-func (j jsonOp) toOp() (PrimitiveOp, error) {
+func (j rawJSONOp) toOp(t TokenArrayType) (PrimitiveOp, error) {
 	switch j.Type {
 	case "noop":
 		return NoOp{}, nil
 	case "retain":
-		return Retain{Count: j.Count}, nil
+		return Retain{Count: j.Count, Attributes: j.Attributes}, nil
 	case "delete":
 		return Delete{Count: j.Count}, nil
 	case "insert":
-		return Insert{Tokens: j.Tokens}, nil
+		tokens, err := UnmarshalTokenArray(j.Tokens, t)
+		if err != nil {
+			return nil, err
+		}
+		return Insert{Tokens: tokens, Attributes: j.Attributes}, nil
 	}
 	return nil, ErrUnknownOp
 }
 
-// UnmarshalJSON decodes a CompositeOp from JSON.
+// UnmarshalTokenArray decodes a TokenArray from JSON using the given TokenArrayType, which has to implement
+// TokenArrayUnmarshaler.
+func UnmarshalTokenArray(data []byte, t TokenArrayType) (TokenArray, error) {
+	unmarshaler, ok := t.(TokenArrayUnmarshaler)
+	if !ok {
+		return nil, ErrNoTokenArrayType
+	}
+	return unmarshaler.UnmarshalTokenArray(data)
+}
+
+// UnmarshalCompositeOp decodes a CompositeOp from JSON, decoding the tokens of its insert operations using the given
+// TokenArrayType, which has to implement TokenArrayUnmarshaler.
 //
-// Check the type documentation for information on the format.
-func (c *CompositeOp) UnmarshalJSON(data []byte) error {
-	var ops []jsonOp
+// Check the CompositeOp type documentation for information on the format.
+func UnmarshalCompositeOp(data []byte, t TokenArrayType) (CompositeOp, error) {
+	var ops []rawJSONOp
 	if err := json.Unmarshal(data, &ops); err != nil {
-		return err
+		return nil, err
 	}
 
-	newC := make(CompositeOp, len(ops))
+	c := make(CompositeOp, len(ops))
 	for i, op := range ops {
-		unmarshalled, err := op.toOp()
+		unmarshalled, err := op.toOp(t)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		newC[i] = unmarshalled
+		c[i] = unmarshalled
+	}
+	return c, nil
+}
+
+// UnmarshalJSON decodes a CompositeOp from JSON.
+//
+// The type of tokens cannot be inferred from JSON, so ErrNoTokenArrayType is returned if the operation contains an
+// insert operation. Use UnmarshalCompositeOp to decode such operations.
+//
+// Check the type documentation for information on the format.
+func (c *CompositeOp) UnmarshalJSON(data []byte) error {
+	newC, err := UnmarshalCompositeOp(data, nil)
+	if err != nil {
+		return err
 	}
 
 	*c = newC
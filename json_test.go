@@ -0,0 +1,133 @@
+package gollab_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/danielslee/gollab/runetoken"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/server"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+
+			data, err := json.Marshal(op)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := gollab.UnmarshalCompositeOp(data, runetoken.ArrayType{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(op, decoded) {
+				t.Errorf("decoded(%v) != op(%v)", decoded, op)
+			}
+		})
+	}
+}
+
+func TestJSONUnmarshalWithoutTokenArrayType(t *testing.T) {
+	var op gollab.CompositeOp
+	if err := json.Unmarshal([]byte(`[{"type":"retain","count":2},{"type":"delete","count":1}]`), &op); err != nil {
+		t.Fatal(err)
+	}
+	if expected := gollab.NewCompositeOp(gollab.Retain{Count: 2}, gollab.Delete{Count: 1}); !reflect.DeepEqual(op,
+		expected) {
+		t.Errorf("expected %v, got %v", expected, op)
+	}
+
+	err := json.Unmarshal([]byte(`[{"type":"insert","tokens":"a"}]`), &op)
+	if err != gollab.ErrNoTokenArrayType {
+		t.Errorf("expected ErrNoTokenArrayType, got %v", err)
+	}
+}
+
+func TestJSONServerMessages(t *testing.T) {
+	initMsg := server.InitMessage{
+		Document: runetoken.Array("hello"),
+		Revision: 3,
+		Presence: []server.PresenceMessage{{ClientID: 1, UserID: "1", Selection: gollab.Selection{Anchor: 1, Head: 2}}},
+	}
+	data, err := json.Marshal(initMsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedInit, err := server.UnmarshalInitMessage(data, runetoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(initMsg, decodedInit) {
+		t.Errorf("expected %+v, got %+v", initMsg, decodedInit)
+	}
+
+	opMsg := server.OpMessage{
+		AuthorID: "1",
+		Op:       gollab.NewCompositeOp(gollab.Retain{Count: 5}, gollab.Insert{Tokens: runetoken.Array("!")}),
+		Revision: 3,
+	}
+	data, err = json.Marshal(opMsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedOp, err := server.UnmarshalOpMessage(data, runetoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(opMsg, decodedOp) {
+		t.Errorf("expected %+v, got %+v", opMsg, decodedOp)
+	}
+}
+
+func TestCompactJSONRoundTrip(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+
+			data, err := gollab.MarshalCompactJSON(op)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := gollab.UnmarshalCompactJSON(data, runetoken.ArrayType{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(op, decoded) {
+				t.Errorf("decoded(%v) != op(%v)", decoded, op)
+			}
+		})
+	}
+}
+
+func TestCompactJSON(t *testing.T) {
+	op := gollab.NewCompositeOp(
+		gollab.Delete{Count: 1},
+		gollab.Insert{Tokens: runetoken.Array("H")},
+		gollab.Retain{Count: 4},
+		gollab.Insert{Tokens: runetoken.Array(", World")})
+
+	data, err := gollab.MarshalCompactJSON(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `["H",-1,4,", World"]`; string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	if _, err := gollab.UnmarshalCompactJSON([]byte(`[1,0]`), runetoken.ArrayType{}); err != gollab.ErrInvalidCount {
+		t.Errorf("expected ErrInvalidCount, got %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+/*
+Package linetoken provides an implementation of the gollab.TokenArray for text documents whose tokens are whole lines.
+
+Each token is a line including its terminating newline, so concatenating the tokens yields the original text. Only the
+last line of a document split by New lacks a newline. Concurrent operations appending to such a line may however leave
+unterminated lines in the middle of a document, as transforming them cannot insert a newline between them, in which
+case concatenating the tokens joins them. Operating on lines makes operations on large files such as source code or
+configuration much smaller, and the history of a document diff-friendly.
+
+ToRuneOp and FromRuneOp convert operations between lines and runes, so a document may be edited using both the linetoken
+and the runetoken representation.
+*/
+package linetoken
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/runetoken"
+)
+
+// ErrInvalidLine is an error indicating that a token isn't a single non-empty line.
+var ErrInvalidLine = errors.New("invalid line")
+
+// Array implements the TokenArray interface as a slice of lines.
+//
+// Arrays are encoded to JSON as arrays of strings, one per line.
+type Array []string
+
+// Split splits a string into lines, each including its terminating newline.
+func Split(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// validLine reports whether a line is non-empty and contains no newline other than its terminating one.
+func validLine(line string) bool {
+	i := strings.IndexByte(line, '\n')
+	return line != "" && (i == -1 || i == len(line)-1)
+}
+
+// checkLines returns ErrInvalidLine unless each of the lines is valid.
+func checkLines(lines []string) error {
+	for _, line := range lines {
+		if !validLine(line) {
+			return ErrInvalidLine
+		}
+	}
+	return nil
+}
+
+// New splits a string into lines, returning them as an Array.
+func New(s string) Array {
+	return Split(s)
+}
+
+// Type returns the ArrayType.
+func (Array) Type() gollab.TokenArrayType {
+	return ArrayType{}
+}
+
+// At returns an element given its index.
+func (t Array) At(idx int) interface{} {
+	return t[idx]
+}
+
+// TokenAt returns an element given its index. It implements gollab.TypedTokenArray.
+func (t Array) TokenAt(idx int) string {
+	return t[idx]
+}
+
+// AppendTokens appends the lines between start and end to dst. It implements gollab.TypedTokenArray.
+func (t Array) AppendTokens(dst []string, start, end int) []string {
+	return append(dst, t[start:end]...)
+}
+
+// Slice slices the array.
+func (t Array) Slice(start, end int) gollab.TokenArray {
+	return t[start:end]
+}
+
+// Len returns the length of the array.
+func (t Array) Len() int {
+	return len(t)
+}
+
+// String returns the Array as a string.
+func (t Array) String() string {
+	return strings.Join(t, "")
+}
+
+// ApplyOp applies an operation to the array using gollab.ApplyTokens, returning the resulting Array. ErrInvalidLine is
+// returned if the operation inserts an invalid line. It implements gollab.TokenArrayEditor.
+func (t Array) ApplyOp(op gollab.CompositeOp) (gollab.TokenArray, error) {
+	for _, p := range op {
+		if i, ok := p.(gollab.Insert); ok {
+			if lines, ok := i.Tokens.(Array); ok {
+				if err := checkLines(lines); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	res, err := gollab.ApplyTokens(op, []string(t))
+	if err != nil {
+		return nil, err
+	}
+	return Array(res), nil
+}
+
+// ArrayType contains methods related to Array.
+type ArrayType struct{}
+
+// NewBuilder creates a new ArrayBuilder.
+func (ArrayType) NewBuilder() gollab.TokenArrayBuilder {
+	return &ArrayBuilder{
+		Array: []string{},
+	}
+}
+
+// Concat concatenates two TokenArrays and returns the result.
+func (ArrayType) Concat(a, b gollab.TokenArray) gollab.TokenArray {
+	la, ok := a.(Array)
+	if !ok {
+		panic("ArrayType.Concat: expected param a to be of type Array")
+	}
+	lb, ok := b.(Array)
+	if !ok {
+		panic("ArrayType.Concat: expected param b to be of type Array")
+	}
+
+	newTokens := make(Array, len(la)+len(lb))
+	copy(newTokens, la)
+	copy(newTokens[len(la):], lb)
+	return newTokens
+}
+
+// UnmarshalTokenArray decodes an Array from a JSON array of lines, returning ErrInvalidLine if they aren't valid lines.
+// A plain JSON string is accepted too, in which case it is split into lines. It implements gollab.TokenArrayUnmarshaler.
+func (ArrayType) UnmarshalTokenArray(data []byte) (gollab.TokenArray, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return New(s), nil
+	}
+
+	var t Array
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	if err := checkLines(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ArrayBuilder is a TokenArrayBuilder implementation using Array.
+type ArrayBuilder struct {
+	Array Array
+}
+
+// Write appends a given line to the builder's Array. ErrInvalidLine is returned if the token isn't a single line. It
+// implements gollab.TypedTokenArrayBuilder.
+func (b *ArrayBuilder) Write(line string) error {
+	if !validLine(line) {
+		return ErrInvalidLine
+	}
+	b.Array = append(b.Array, line)
+	return nil
+}
+
+// WriteToken appends a given token to the builder's Array.
+func (b *ArrayBuilder) WriteToken(token interface{}) error {
+	s, ok := token.(string)
+	if !ok {
+		return errors.New("ArrayBuilder.WriteToken: expected a string")
+	}
+	return b.Write(s)
+}
+
+// TokenArray returns the Array.
+func (b ArrayBuilder) TokenArray() gollab.TokenArray {
+	return b.Array
+}
+
+// ApplyToString applies the operation to a plain Go string split into lines, returning the result as a plain Go string.
+// This assumes the operation consumes and outputs lines only.
+func ApplyToString(op gollab.Op, text string) (string, error) {
+	t := New(text)
+	if op.InputLength() != len(t) {
+		return "", gollab.ErrLengthMismatch
+	}
+
+	if c, ok := op.(gollab.CompositeOp); ok {
+		applied, err := t.ApplyOp(c)
+		if err != nil {
+			return "", err
+		}
+		return applied.(Array).String(), nil
+	}
+
+	var out ArrayBuilder
+	if err := op.Apply(gollab.NewTokenArrayReader(t), &out); err != nil {
+		return "", err
+	}
+	return out.Array.String(), nil
+}
+
+// runeCount returns the number of runes in the given lines.
+func runeCount(lines []string) (n int) {
+	for _, line := range lines {
+		n += utf8.RuneCountInString(line)
+	}
+	return
+}
+
+// ToRuneOp converts an operation on lines into an equivalent runetoken operation. base is the document the operation
+// applies to.
+func ToRuneOp(op gollab.CompositeOp, base Array) (gollab.CompositeOp, error) {
+	if op.InputLength() != len(base) {
+		return nil, gollab.ErrLengthMismatch
+	}
+
+	ops := make([]gollab.PrimitiveOp, 0, len(op))
+	var idx int
+	for _, p := range op {
+		switch p := p.(type) {
+		case gollab.Retain:
+			if p.Count < 0 || idx+p.Count > len(base) {
+				return nil, gollab.ErrInvalidCount
+			}
+			ops = append(ops, gollab.Retain{Count: runeCount(base[idx : idx+p.Count]), Attributes: p.Attributes})
+			idx += p.Count
+		case gollab.Delete:
+			if p.Count < 0 || idx+p.Count > len(base) {
+				return nil, gollab.ErrInvalidCount
+			}
+			ops = append(ops, gollab.Delete{Count: runeCount(base[idx : idx+p.Count])})
+			idx += p.Count
+		case gollab.Insert:
+			lines, ok := p.Tokens.(Array)
+			if !ok {
+				return nil, gollab.ErrUnexpectedTokenType
+			}
+			ops = append(ops, gollab.Insert{Tokens: runetoken.Array(lines.String()), Attributes: p.Attributes})
+		}
+	}
+	return gollab.NewCompositeOp(ops...), nil
+}
+
+// FromRuneOp converts a runetoken operation into an equivalent operation on lines. base is the document the operation
+// applies to.
+//
+// A runetoken operation may edit parts of lines, which cannot be expressed by an operation on lines. Lines changed by
+// the operation are therefore replaced as a whole, by diffing the lines of base against the lines of the result of
+// applying the operation.
+func FromRuneOp(op gollab.CompositeOp, base Array) (gollab.CompositeOp, error) {
+	applied, err := runetoken.ApplyToString(op, base.String())
+	if err != nil {
+		return nil, err
+	}
+
+	newLines := New(applied)
+	return gollab.DiffFunc(base, newLines, func(i, j int) bool {
+		return base[i] == newLines[j]
+	}), nil
+}
@@ -0,0 +1,204 @@
+package gollab_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/linetoken"
+	"github.com/danielslee/gollab/runetoken"
+	"github.com/danielslee/gollab/server"
+)
+
+func TestLineSplit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"", []string{}},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a\n"}},
+		{"a\r\nb\n\nc", []string{"a\r\n", "b\n", "\n", "c"}},
+	}
+
+	for _, test := range tests {
+		if lines := linetoken.Split(test.input); !reflect.DeepEqual(lines, test.expected) {
+			t.Errorf("Split(%q): expected %q, got %q", test.input, test.expected, lines)
+		}
+	}
+}
+
+func TestLineApplyToString(t *testing.T) {
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 1},
+		gollab.Delete{Count: 1},
+		gollab.Insert{Tokens: linetoken.Array{"port = 8080\n"}},
+		gollab.Retain{Count: 1},
+	)
+
+	applied, err := linetoken.ApplyToString(op, "[server]\nport = 80\nhost = example.com\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[server]\nport = 8080\nhost = example.com\n"; applied != expected {
+		t.Errorf("expected %q, got %q", expected, applied)
+	}
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := gollab.UnmarshalCompositeOp(data, linetoken.ArrayType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, op) {
+		t.Errorf("expected %v, got %v", op, decoded)
+	}
+}
+
+func randomLines(n int) linetoken.Array {
+	lines := make(linetoken.Array, n)
+	for i := range lines {
+		lines[i] = randString(rand.Intn(4)) + "\n"
+	}
+	return lines
+}
+
+func TestLineRuneOpConversion(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			base := randomLines(rand.Intn(10) + 1)
+
+			lineOp := gollab.NewCompositeOp(
+				gollab.Retain{Count: rand.Intn(base.Len())},
+				gollab.Insert{Tokens: randomLines(rand.Intn(3) + 1)},
+			)
+			lineOp = append(lineOp, gollab.Delete{Count: base.Len() - lineOp.InputLength()})
+			lineOp = gollab.NewCompositeOp(lineOp...)
+
+			expected, err := linetoken.ApplyToString(lineOp, base.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			runeOp, err := linetoken.ToRuneOp(lineOp, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if applied, err := runetoken.ApplyToString(runeOp, base.String()); err != nil || applied != expected {
+				t.Errorf("ToRuneOp: expected %q, got %q (%v)", expected, applied, err)
+			}
+
+			text := []rune(base.String())
+			runeOp = randomCompositeOp(len(text), len(text)/2+rand.Intn(10))
+			expected, err = runetoken.ApplyToString(runeOp, string(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			lineOp, err = linetoken.FromRuneOp(runeOp, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if applied, err := linetoken.ApplyToString(lineOp, base.String()); err != nil || applied != expected {
+				t.Errorf("FromRuneOp: expected %q, got %q (%v)", expected, applied, err)
+			}
+		})
+	}
+}
+
+func TestLineToRuneOpAttributes(t *testing.T) {
+	base := linetoken.New("a\nbc\n")
+	bold := gollab.Attributes{"bold": true}
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 1, Attributes: bold},
+		gollab.Insert{Tokens: linetoken.Array{"x\n"}, Attributes: bold},
+		gollab.Retain{Count: 1},
+	)
+
+	runeOp, err := linetoken.ToRuneOp(op, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := gollab.NewCompositeOp(
+		gollab.Retain{Count: 2, Attributes: bold},
+		gollab.Insert{Tokens: runetoken.Array("x\n"), Attributes: bold},
+		gollab.Retain{Count: 3},
+	)
+	if !reflect.DeepEqual(runeOp, expected) {
+		t.Errorf("expected %v, got %v", expected, runeOp)
+	}
+}
+
+func TestLineFromRuneOpKeepsUnchangedLines(t *testing.T) {
+	base := linetoken.New(strings.Repeat("line\n", 5))
+	runeOp := gollab.NewCompositeOp(
+		gollab.Retain{Count: 12},
+		gollab.Insert{Tokens: runetoken.Array("X")},
+		gollab.Retain{Count: 13},
+	)
+
+	lineOp, err := linetoken.FromRuneOp(runeOp, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := gollab.NewCompositeOp(
+		gollab.Retain{Count: 2},
+		gollab.Insert{Tokens: linetoken.Array{"liXne\n"}},
+		gollab.Delete{Count: 1},
+		gollab.Retain{Count: 2},
+	)
+	if !reflect.DeepEqual(lineOp, expected) {
+		t.Errorf("expected %v, got %v", expected, lineOp)
+	}
+}
+
+func TestLineInvalid(t *testing.T) {
+	base := linetoken.New("a\nb\n")
+	for _, op := range []gollab.CompositeOp{
+		gollab.NewCompositeOp(gollab.Retain{Count: 1}, gollab.Insert{Tokens: linetoken.Array{"x\ny\n"}},
+			gollab.Retain{Count: 1}),
+		gollab.NewCompositeOp(gollab.Retain{Count: 2}, gollab.Insert{Tokens: linetoken.Array{""}}),
+		gollab.NewCompositeOp(gollab.Insert{Tokens: linetoken.Array{"x\ny"}}, gollab.Retain{Count: 2}),
+	} {
+		if _, err := op.ApplyToTokenArray(base); !errors.Is(err, linetoken.ErrInvalidLine) {
+			t.Errorf("ApplyOp(%v): expected ErrInvalidLine, got %v", op, err)
+		}
+	}
+
+	var builder linetoken.ArrayBuilder
+	for _, line := range []string{"a\nb\n", ""} {
+		if err := builder.Write(line); !errors.Is(err, linetoken.ErrInvalidLine) {
+			t.Errorf("Write(%q): expected ErrInvalidLine, got %v", line, err)
+		}
+	}
+
+	if _, err := (linetoken.ArrayType{}).UnmarshalTokenArray([]byte(`["a","b\nc"]`)); !errors.Is(err,
+		linetoken.ErrInvalidLine) {
+		t.Errorf("UnmarshalTokenArray: expected ErrInvalidLine, got %v", err)
+	}
+}
+
+func TestLineConcurrentAppend(t *testing.T) {
+	store := server.NewMemoryStateStore(linetoken.New("a\n"))
+	for _, msg := range []server.OpMessage{
+		{AuthorID: "1", Op: gollab.NewCompositeOp(gollab.Retain{Count: 1}, gollab.Insert{Tokens: linetoken.Array{"x"}})},
+		{AuthorID: "2", Op: gollab.NewCompositeOp(gollab.Retain{Count: 1}, gollab.Insert{Tokens: linetoken.Array{"y"}})},
+	} {
+		if err := store.ApplyClient(msg); err != nil {
+			t.Fatalf("ApplyClient: %v", err)
+		}
+		<-store.OperationStream()
+	}
+
+	document, revision, _ := store.Current()
+	if lines := document.(linetoken.Array); revision != 2 || !reflect.DeepEqual(lines, linetoken.Array{"a\n", "y", "x"}) {
+		t.Errorf("expected both lines to be appended, got %q at revision %d", lines, revision)
+	}
+}
@@ -27,11 +27,19 @@ func (n NoOp) Apply(TokenReader, TokenWriter) error {
 //
 // The output length of the first operation has to equal the input length of the other one.
 func (n NoOp) Compose(b PrimitiveOp) PrimitiveOp {
+	c, err := n.tryCompose(b)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (n NoOp) tryCompose(b PrimitiveOp) (PrimitiveOp, error) {
 	switch b := b.(type) {
 	case Insert:
-		return b
+		return b, nil
 	default:
-		panic(ErrUnexpectedOp)
+		return nil, ErrUnexpectedOp
 	}
 }
 
@@ -39,14 +47,32 @@ func (n NoOp) Compose(b PrimitiveOp) PrimitiveOp {
 //
 // The input length of both operations has to be equal.
 func (n NoOp) Transform(b PrimitiveOp) (aPrime, bPrime PrimitiveOp) {
+	aPrime, bPrime, err := n.tryTransform(b)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (n NoOp) tryTransform(b PrimitiveOp) (aPrime, bPrime PrimitiveOp, err error) {
 	switch b := b.(type) {
 	case Insert:
-		return Retain{Count: b.OutputLength()}, b
+		return Retain{Count: b.OutputLength()}, b, nil
 	default:
-		panic(ErrUnexpectedOp)
+		return nil, nil, ErrUnexpectedOp
 	}
 }
 
+// Invert returns the inverse of the operation, which is another NoOp.
+func (n NoOp) Invert(TokenArray) PrimitiveOp {
+	return NoOp{}
+}
+
+// Validate checks whether the operation is valid. A NoOp is always valid on its own.
+func (n NoOp) Validate() error {
+	return nil
+}
+
 // String provides a string representation of the NoOp operation.
 func (n NoOp) String() string {
 	return "NoOp"
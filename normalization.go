@@ -43,10 +43,23 @@ func swapOps(ops []PrimitiveOp) []PrimitiveOp {
 	return ops
 }
 
+// isNoOp returns true for NoOps and for Retain and Delete operations of zero tokens, which don't change anything.
+func isNoOp(o PrimitiveOp) bool {
+	switch o := o.(type) {
+	case NoOp:
+		return true
+	case Retain:
+		return o.Count == 0
+	case Delete:
+		return o.Count == 0
+	}
+	return false
+}
+
 func removeNoOps(ops []PrimitiveOp) []PrimitiveOp {
 	i := 0
 	for _, o := range ops {
-		if _, ok := o.(NoOp); !ok {
+		if !isNoOp(o) {
 			ops[i] = o
 			i++
 		}
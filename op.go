@@ -2,6 +2,7 @@ package gollab
 
 import (
 	"errors"
+	"fmt"
 )
 
 // Op represents any operation which can be applied.
@@ -22,6 +23,20 @@ type PrimitiveOp interface {
 	Transform(b PrimitiveOp) (aPrime, bPrime PrimitiveOp)
 }
 
+// Inverter is implemented by PrimitiveOps which can be inverted. It is required by CompositeOp.Invert and implemented by
+// all PrimitiveOps of this package.
+type Inverter interface {
+	// Invert returns the inverse of the operation given the tokens it consumes.
+	Invert(input TokenArray) PrimitiveOp
+}
+
+// Validator is implemented by PrimitiveOps able to check whether they are valid. It is used by CompositeOp.Validate,
+// which considers PrimitiveOps not implementing it valid, and implemented by all PrimitiveOps of this package.
+type Validator interface {
+	// Validate returns an error describing why the operation is invalid, or nil if it is valid.
+	Validate() error
+}
+
 // ErrLengthMismatch length mismatch error
 var ErrLengthMismatch = errors.New("length mismatch")
 
@@ -31,14 +46,139 @@ var ErrUnexpectedOp = errors.New("unexpected operation")
 // ErrInvalidSlice invalid slice error
 var ErrInvalidSlice = errors.New("invalid slice")
 
-func checkComposeLength(a, b Op) {
+// ErrInvalidCount invalid count error
+var ErrInvalidCount = errors.New("invalid count")
+
+// ErrNilTokens nil tokens error
+var ErrNilTokens = errors.New("nil tokens")
+
+// ErrMixedTokenTypes mixed token types error
+var ErrMixedTokenTypes = errors.New("mixed token types")
+
+// ErrNotNormalized operation not normalized error
+var ErrNotNormalized = errors.New("operation not normalized")
+
+// LengthMismatchError is returned by CompositeOp.TryTransform and CompositeOp.TryCompose when the lengths of the two
+// operations don't match. It wraps ErrLengthMismatch.
+//
+// AIndex and BIndex are the indexes of the offending PrimitiveOps within the two operations. They equal -1 if the total
+// lengths of the operations don't match, and the length of the operation if it ended prematurely.
+type LengthMismatchError struct {
+	AIndex, BIndex   int
+	ALength, BLength int
+}
+
+func (e *LengthMismatchError) Error() string {
+	if e.AIndex < 0 && e.BIndex < 0 {
+		return fmt.Sprintf("length mismatch: %d != %d", e.ALength, e.BLength)
+	}
+	return fmt.Sprintf("length mismatch: a[%d] (length %d), b[%d] (length %d)", e.AIndex, e.ALength, e.BIndex,
+		e.BLength)
+}
+
+// Unwrap returns ErrLengthMismatch.
+func (e *LengthMismatchError) Unwrap() error {
+	return ErrLengthMismatch
+}
+
+// UnexpectedOpError is returned by CompositeOp.TryTransform and CompositeOp.TryCompose when two PrimitiveOps cannot be
+// transformed or composed with each other. It wraps ErrUnexpectedOp.
+//
+// AIndex and BIndex are the indexes of the offending PrimitiveOps within the two operations.
+type UnexpectedOpError struct {
+	AIndex, BIndex int
+	A, B           PrimitiveOp
+}
+
+func (e *UnexpectedOpError) Error() string {
+	return fmt.Sprintf("unexpected operation: a[%d] (%v), b[%d] (%v)", e.AIndex, e.A, e.BIndex, e.B)
+}
+
+// Unwrap returns ErrUnexpectedOp.
+func (e *UnexpectedOpError) Unwrap() error {
+	return ErrUnexpectedOp
+}
+
+// InvalidOpError is returned by CompositeOp.Validate when one of its PrimitiveOps is invalid. It wraps the reason, which
+// is one of ErrInvalidCount, ErrNilTokens, ErrMixedTokenTypes, ErrNotNormalized or ErrUnknownOp.
+//
+// Index is the index of the offending PrimitiveOp within the operation.
+type InvalidOpError struct {
+	Index int
+	Op    PrimitiveOp
+	Err   error
+}
+
+func (e *InvalidOpError) Error() string {
+	return fmt.Sprintf("invalid operation at index %d (%v): %v", e.Index, e.Op, e.Err)
+}
+
+// Unwrap returns the reason the operation is invalid.
+func (e *InvalidOpError) Unwrap() error {
+	return e.Err
+}
+
+// tryPrimitiveOp is implemented by all PrimitiveOps of this package, providing error-returning variants of Compose and Transform.
+type tryPrimitiveOp interface {
+	tryCompose(b PrimitiveOp) (PrimitiveOp, error)
+	tryTransform(b PrimitiveOp) (aPrime, bPrime PrimitiveOp, err error)
+}
+
+// tryCompose composes two PrimitiveOps, falling back to PrimitiveOp.Compose for operations defined outside of this
+// package. Their panics with ErrLengthMismatch or ErrUnexpectedOp are returned as errors.
+func tryCompose(a, b PrimitiveOp) (res PrimitiveOp, err error) {
+	if a, ok := a.(tryPrimitiveOp); ok {
+		return a.tryCompose(b)
+	}
+	defer recoverOpError(&err)
+	return a.Compose(b), nil
+}
+
+// tryTransform transforms two PrimitiveOps, falling back to PrimitiveOp.Transform for operations defined outside of
+// this package. Their panics with ErrLengthMismatch or ErrUnexpectedOp are returned as errors.
+func tryTransform(a, b PrimitiveOp) (aPrime, bPrime PrimitiveOp, err error) {
+	if a, ok := a.(tryPrimitiveOp); ok {
+		return a.tryTransform(b)
+	}
+	defer recoverOpError(&err)
+	aPrime, bPrime = a.Transform(b)
+	return
+}
+
+// recoverOpError recovers from a panic with ErrLengthMismatch or ErrUnexpectedOp, storing it in err. Other panics are
+// propagated.
+func recoverOpError(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if e, ok := r.(error); ok {
+		for _, target := range []error{ErrLengthMismatch, ErrUnexpectedOp} {
+			if errors.Is(e, target) {
+				*err = target
+				return
+			}
+		}
+	}
+	panic(r)
+}
+
+func checkComposeLength(a, b Op) error {
 	if a.OutputLength() != b.InputLength() {
-		panic(ErrLengthMismatch)
+		return ErrLengthMismatch
 	}
+	return nil
 }
 
-func checkTransformLength(a, b Op) {
+func checkTransformLength(a, b Op) error {
 	if a.InputLength() != b.InputLength() {
+		return ErrLengthMismatch
+	}
+	return nil
+}
+
+func checkInvertLength(a Op, input TokenArray) {
+	if a.InputLength() != input.Len() {
 		panic(ErrLengthMismatch)
 	}
 }
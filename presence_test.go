@@ -0,0 +1,205 @@
+package gollab_test
+
+import (
+	"github.com/danielslee/gollab/runetoken"
+	"testing"
+	"time"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/server"
+)
+
+func receive(t *testing.T, c <-chan interface{}) interface{} {
+	select {
+	case msg := <-c:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+	}
+	return nil
+}
+
+func TestPresence(t *testing.T) {
+	d := server.NewDocumentServer(server.NewMemoryStateStore(runetoken.Array("hello world")))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run()
+	}()
+
+	id1, c1 := d.NewClient()
+	id2, c2 := d.NewClient()
+	receive(t, c1)
+	receive(t, c2)
+
+	d.ReceiveChan() <- server.ClientMessage{
+		ClientID: id1,
+		Presence: &server.PresenceMessage{
+			UserID:    "1",
+			Name:      "One",
+			Color:     "#ff0000",
+			Selection: gollab.Selection{Anchor: 6, Head: 11},
+			Revision:  0,
+		},
+	}
+
+	presence, ok := receive(t, c2).(server.PresenceMessage)
+	if !ok {
+		t.Fatal("expected a PresenceMessage")
+	}
+	if presence.ClientID != id1 || presence.Selection != (gollab.Selection{Anchor: 6, Head: 11}) {
+		t.Errorf("unexpected presence: %+v", presence)
+	}
+
+	d.ReceiveChan() <- server.ClientMessage{
+		ClientID: id2,
+		Message: server.OpMessage{
+			AuthorID: "2",
+			Op: gollab.NewCompositeOp(gollab.Retain{Count: 5}, gollab.Insert{Tokens: runetoken.Array(",")},
+				gollab.Retain{Count: 6}),
+			Revision: 0,
+		},
+	}
+	receive(t, c1)
+	receive(t, c2)
+
+	// a presence message referring to an old revision is transformed before being broadcast, the caret of the
+	// operation's author ending up after the inserted tokens
+	d.ReceiveChan() <- server.ClientMessage{
+		ClientID: id2,
+		Presence: &server.PresenceMessage{
+			UserID:    "2",
+			Selection: gollab.Selection{Anchor: 5, Head: 5},
+			Revision:  0,
+		},
+	}
+	presence, ok = receive(t, c1).(server.PresenceMessage)
+	if !ok {
+		t.Fatal("expected a PresenceMessage")
+	}
+	if presence.Revision != 1 || presence.Selection != (gollab.Selection{Anchor: 6, Head: 6}) {
+		t.Errorf("unexpected presence: %+v", presence)
+	}
+
+	id3, c3 := d.NewClient()
+	init, ok := receive(t, c3).(server.InitMessage)
+	if !ok {
+		t.Fatal("expected an InitMessage")
+	}
+	if len(init.Presence) != 2 {
+		t.Fatalf("expected 2 presence entries, got %d", len(init.Presence))
+	}
+	if p := init.Presence[0]; p.ClientID != id1 || p.Selection != (gollab.Selection{Anchor: 7, Head: 12}) {
+		t.Errorf("unexpected presence: %+v", p)
+	}
+
+	d.RemoveClient(id1)
+	for _, c := range []<-chan interface{}{c2, c3} {
+		removed, ok := receive(t, c).(server.PresenceRemovedMessage)
+		if !ok || removed.ClientID != id1 || removed.UserID != "1" {
+			t.Errorf("unexpected message: %+v", removed)
+		}
+	}
+
+	d.RemoveClient(id3)
+	close(d.ReceiveChan())
+	<-done
+}
+
+func TestPresenceClearedOnError(t *testing.T) {
+	d := server.NewDocumentServer(server.NewMemoryStateStore(runetoken.Array("hello")))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run()
+	}()
+
+	id1, c1 := d.NewClient()
+	_, c2 := d.NewClient()
+	receive(t, c1)
+	receive(t, c2)
+
+	d.ReceiveChan() <- server.ClientMessage{
+		ClientID: id1,
+		Presence: &server.PresenceMessage{UserID: "1", Selection: gollab.Selection{Anchor: 1, Head: 1}},
+	}
+	receive(t, c2)
+
+	// a client dropped for sending an invalid operation has its presence cleared too
+	d.ReceiveChan() <- server.ClientMessage{
+		ClientID: id1,
+		Message:  server.OpMessage{AuthorID: "1", Op: gollab.NewCompositeOp(gollab.Retain{Count: 100})},
+	}
+	if _, ok := receive(t, c1).(server.ErrorMessage); !ok {
+		t.Error("expected an ErrorMessage")
+	}
+	removed, ok := receive(t, c2).(server.PresenceRemovedMessage)
+	if !ok || removed.ClientID != id1 || removed.UserID != "1" {
+		t.Errorf("unexpected message: %+v", removed)
+	}
+
+	close(d.ReceiveChan())
+	<-done
+}
+
+func TestDropSlowClient(t *testing.T) {
+	d := server.NewDocumentServer(server.NewMemoryStateStore(runetoken.Array("")))
+	d.SetDropSlowClients(true)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run()
+	}()
+
+	slowID, slow := d.NewClient()
+	id, c := d.NewClient()
+	receive(t, c)
+
+	d.ReceiveChan() <- server.ClientMessage{
+		ClientID: slowID,
+		Presence: &server.PresenceMessage{UserID: "slow"},
+	}
+	receive(t, c)
+
+	// the slow client never reads its messages, so it gets dropped once its channel is full, clearing its presence
+	var removed bool
+	for i := 0; i < 200; i++ {
+		d.ReceiveChan() <- server.ClientMessage{
+			ClientID: id,
+			Message: server.OpMessage{
+				AuthorID: "fast",
+				Op:       gollab.NewCompositeOp(gollab.Retain{Count: i}, gollab.Insert{Tokens: runetoken.Array("!")}),
+				Revision: i,
+			},
+		}
+		if msg, ok := receive(t, c).(server.PresenceRemovedMessage); ok {
+			if msg.ClientID != slowID || msg.UserID != "slow" {
+				t.Errorf("unexpected message: %+v", msg)
+			}
+			removed = true
+			receive(t, c)
+		}
+	}
+	if !removed {
+		t.Error("expected the presence of the slow client to be removed")
+	}
+
+	var last interface{}
+	var received int
+	for msg := range slow {
+		last = msg
+		received++
+	}
+	if received >= 200 {
+		t.Errorf("expected the slow client to be dropped, got %d messages", received)
+	}
+	if msg, ok := last.(server.ErrorMessage); !ok || msg.Error != server.ErrClientTooSlow.Error() {
+		t.Errorf("expected the slow client to receive ErrClientTooSlow last, got %+v", last)
+	}
+
+	close(d.ReceiveChan())
+	<-done
+}
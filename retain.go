@@ -6,8 +6,12 @@ import (
 
 // Retain represents a retain operation which copies a given number of tokens (Count) from the input over to the
 // output
+//
+// If Attributes are set, the retained tokens are formatted with them, a nil value removing the attribute. See
+// Attributes for more details, including why Retain values cannot be compared using ==.
 type Retain struct {
-	Count int
+	Count      int
+	Attributes Attributes
 }
 
 // InputLength returns the required input length.
@@ -27,7 +31,7 @@ func (r Retain) OutputLength() int {
 // Slice slices the operation.
 func (r Retain) Slice(start, end int) PrimitiveOp {
 	checkSliceValidity(start, end)
-	return Retain{Count: end - start}
+	return Retain{Count: end - start, Attributes: r.Attributes}
 }
 
 // Apply applies the operation
@@ -37,7 +41,7 @@ func (r Retain) Apply(reader TokenReader, writer TokenWriter) error {
 		if err != nil {
 			return err
 		}
-		if err := writer.WriteToken(token); err != nil {
+		if err := writer.WriteToken(formatToken(token, r.Attributes)); err != nil {
 			return err
 		}
 	}
@@ -45,9 +49,11 @@ func (r Retain) Apply(reader TokenReader, writer TokenWriter) error {
 }
 
 // Join joins the operation with another one if possible. This is used for normalization.
+//
+// Retain operations are only joined if their attributes are equal.
 func (r Retain) Join(next PrimitiveOp) PrimitiveOp {
-	if nextRetain, ok := next.(Retain); ok {
-		return Retain{Count: r.Count + nextRetain.Count}
+	if nextRetain, ok := next.(Retain); ok && r.Attributes.Equal(nextRetain.Attributes) {
+		return Retain{Count: r.Count + nextRetain.Count, Attributes: r.Attributes}
 	}
 	return nil
 }
@@ -56,15 +62,25 @@ func (r Retain) Join(next PrimitiveOp) PrimitiveOp {
 //
 // The output length of the first operation has to equal the input length of the other one.
 func (r Retain) Compose(b PrimitiveOp) PrimitiveOp {
-	checkComposeLength(r, b)
+	c, err := r.tryCompose(b)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (r Retain) tryCompose(b PrimitiveOp) (PrimitiveOp, error) {
+	if err := checkComposeLength(r, b); err != nil {
+		return nil, err
+	}
 
 	switch b := b.(type) {
 	case Retain:
-		return r
+		return Retain{Count: r.Count, Attributes: ComposeAttributes(r.Attributes, b.Attributes, true)}, nil
 	case Delete:
-		return b
+		return b, nil
 	default:
-		panic(ErrUnexpectedOp)
+		return nil, ErrUnexpectedOp
 	}
 }
 
@@ -72,19 +88,54 @@ func (r Retain) Compose(b PrimitiveOp) PrimitiveOp {
 //
 // The input length of both operations has to be equal.
 func (r Retain) Transform(b PrimitiveOp) (aPrime, bPrime PrimitiveOp) {
-	checkTransformLength(r, b)
+	aPrime, bPrime, err := r.tryTransform(b)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (r Retain) tryTransform(b PrimitiveOp) (aPrime, bPrime PrimitiveOp, err error) {
+	if err := checkTransformLength(r, b); err != nil {
+		return nil, nil, err
+	}
 
 	switch b := b.(type) {
 	case Retain:
-		return b, b
+		return Retain{Count: r.Count, Attributes: TransformAttributes(b.Attributes, r.Attributes, false)},
+			Retain{Count: b.Count, Attributes: TransformAttributes(r.Attributes, b.Attributes, true)}, nil
 	case Delete:
-		return NoOp{}, b
+		return NoOp{}, b, nil
 	default:
-		panic(ErrUnexpectedOp)
+		return nil, nil, ErrUnexpectedOp
 	}
 }
 
+// Invert returns the inverse of the operation given the tokens it consumes.
+//
+// The inverse of a Retain operation without attributes is the same Retain operation. If it has attributes, the inverse
+// restores the attributes of the input tokens, which are assumed to be equal for all of them. CompositeOp.Invert takes
+// care of splitting operations spanning tokens with different attributes.
+func (r Retain) Invert(input TokenArray) PrimitiveOp {
+	checkInvertLength(r, input)
+	if len(r.Attributes) == 0 || input.Len() == 0 {
+		return Retain{Count: r.Count}
+	}
+	return Retain{Count: r.Count, Attributes: InvertAttributes(r.Attributes, tokenAttributes(input.At(0)))}
+}
+
+// Validate checks whether the operation is valid, returning ErrInvalidCount if its Count isn't positive.
+func (r Retain) Validate() error {
+	if r.Count <= 0 {
+		return ErrInvalidCount
+	}
+	return nil
+}
+
 // String provides a string representation of the Retain operation.
 func (r Retain) String() string {
+	if len(r.Attributes) > 0 {
+		return fmt.Sprintf("Retain(%d, %s)", r.Count, r.Attributes)
+	}
 	return fmt.Sprintf("Retain(%d)", r.Count)
 }
@@ -0,0 +1,105 @@
+package gollab_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/runetoken"
+	"github.com/danielslee/gollab/server"
+)
+
+func insertAtStart(store server.StateStore, revision int, length int) error {
+	op := gollab.NewCompositeOp(gollab.Insert{Tokens: runetoken.Array("!")}, gollab.Retain{Count: length})
+	err := store.ApplyClient(server.OpMessage{Op: op, Revision: revision})
+	if err == nil {
+		<-store.OperationStream()
+	}
+	return err
+}
+
+func TestMemoryStateStoreMaxRevisions(t *testing.T) {
+	store := server.NewMemoryStateStore(runetoken.Array{})
+	store.SetRetentionPolicy(server.RetentionPolicy{MaxRevisions: 3})
+	for _, s := range []string{"a", "b", "c", "d", "e", "f"} {
+		appendToStateStore(t, store, s)
+	}
+
+	if err := insertAtStart(store, 1, 1); !errors.Is(err, server.ErrRevisionTooOld) {
+		t.Errorf("expected ErrRevisionTooOld, got %v", err)
+	}
+	if err := insertAtStart(store, 7, 6); !errors.Is(err, server.ErrUnknownRevision) {
+		t.Errorf("expected ErrUnknownRevision, got %v", err)
+	}
+	if err := insertAtStart(store, 3, 3); err != nil {
+		t.Errorf("ApplyClient: %v", err)
+	}
+
+	document, revision, _ := store.Current()
+	if string(document.(runetoken.Array)) != "!abcdef" || revision != 7 {
+		t.Errorf("expected \"!abcdef\" at revision 7, got %q at revision %d", document, revision)
+	}
+}
+
+func TestMemoryStateStoreMaxAge(t *testing.T) {
+	store := server.NewMemoryStateStore(runetoken.Array{})
+	store.SetRetentionPolicy(server.RetentionPolicy{MaxAge: 10 * time.Millisecond})
+	appendToStateStore(t, store, "a")
+	appendToStateStore(t, store, "b")
+	if err := insertAtStart(store, 1, 1); err != nil {
+		t.Errorf("ApplyClient: %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	appendToStateStore(t, store, "c")
+	if err := insertAtStart(store, 1, 1); !errors.Is(err, server.ErrRevisionTooOld) {
+		t.Errorf("expected ErrRevisionTooOld, got %v", err)
+	}
+}
+
+func logSize(t *testing.T, dir string) int64 {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestFileStateStoreRetention(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStateStore(t, dir, 5)
+	store.SetRetentionPolicy(server.RetentionPolicy{MaxRevisions: 5})
+	var expected string
+	var size5 int64
+	for i := 0; i < 20; i++ {
+		appendToStateStore(t, store, string(rune('a'+i)))
+		expected += string(rune('a' + i))
+		if i == 4 {
+			size5 = logSize(t, dir)
+		}
+	}
+	if err := insertAtStart(store, 10, 10); !errors.Is(err, server.ErrRevisionTooOld) {
+		t.Errorf("expected ErrRevisionTooOld, got %v", err)
+	}
+
+	if size := logSize(t, dir); size >= 2*size5 {
+		t.Errorf("expected the log to be compacted, got %d bytes compared to %d bytes for 5 operations", size, size5)
+	}
+	store.Close()
+
+	// Only the operations after the base revision are kept in the log.
+	store = openFileStateStore(t, dir, 5)
+	store.SetRetentionPolicy(server.RetentionPolicy{MaxRevisions: 5})
+	checkFileStateStore(t, store, expected, 20)
+	if err := insertAtStart(store, 16, 16); err != nil {
+		t.Errorf("ApplyClient: %v", err)
+	}
+	if err := insertAtStart(store, 10, 10); !errors.Is(err, server.ErrRevisionTooOld) {
+		t.Errorf("expected ErrRevisionTooOld, got %v", err)
+	}
+	store.Close()
+}
@@ -0,0 +1,216 @@
+/*
+Package richtoken provides an implementation of the gollab.TokenArray for rich text, where each token is a rune along
+with its formatting attributes.
+
+Chars implement gollab.AttributedToken, so they are formatted by Retain and Insert operations carrying attributes:
+
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 5, Attributes: gollab.Attributes{"bold": true}},
+		gollab.Insert{Tokens: richtoken.New("!", nil), Attributes: gollab.Attributes{"italic": true}},
+	)
+
+Arrays are encoded to JSON as arrays of runs of characters sharing the same attributes, similarly to Quill deltas:
+
+	[{"text": "Hello", "attributes": {"bold": true}}, {"text": "!", "attributes": {"italic": true}}]
+*/
+package richtoken
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/danielslee/gollab"
+)
+
+// Char is a rune along with its formatting attributes. Chars cannot be compared using ==, use Equal instead.
+type Char struct {
+	Rune       rune
+	Attributes gollab.Attributes
+}
+
+// Equal reports whether the Char equals another Char, having the same rune and attributes. It implements
+// gollab.EqualToken.
+func (c Char) Equal(other interface{}) bool {
+	o, ok := other.(Char)
+	return ok && c.Rune == o.Rune && c.Attributes.Equal(o.Attributes)
+}
+
+// TokenAttributes returns the attributes of the Char. It implements gollab.AttributedToken.
+func (c Char) TokenAttributes() gollab.Attributes {
+	return c.Attributes
+}
+
+// Format returns the Char with the given attributes composed onto its own. It implements gollab.AttributedToken.
+func (c Char) Format(attributes gollab.Attributes) interface{} {
+	return Char{Rune: c.Rune, Attributes: gollab.ComposeAttributes(c.Attributes, attributes, false)}
+}
+
+// Run is a run of text sharing the same attributes. It is used to encode Arrays to JSON.
+type Run struct {
+	Text       string            `json:"text"`
+	Attributes gollab.Attributes `json:"attributes,omitempty"`
+}
+
+// Array implements the TokenArray interface as a slice of Chars.
+type Array []Char
+
+// New creates an Array holding the given text formatted with the given attributes.
+func New(text string, attributes gollab.Attributes) Array {
+	t := make(Array, 0, len(text))
+	for _, r := range text {
+		t = append(t, Char{Rune: r, Attributes: attributes})
+	}
+	return t
+}
+
+// FromRuns creates an Array out of runs of text.
+func FromRuns(runs []Run) Array {
+	var t Array
+	for _, run := range runs {
+		t = append(t, New(run.Text, run.Attributes)...)
+	}
+	return t
+}
+
+// Type returns the ArrayType.
+func (Array) Type() gollab.TokenArrayType {
+	return ArrayType{}
+}
+
+// At returns an element given its index.
+func (t Array) At(idx int) interface{} {
+	return t[idx]
+}
+
+// TokenAt returns an element given its index. It implements gollab.TypedTokenArray.
+func (t Array) TokenAt(idx int) Char {
+	return t[idx]
+}
+
+// AppendTokens appends the Chars between start and end to dst. It implements gollab.TypedTokenArray.
+func (t Array) AppendTokens(dst []Char, start, end int) []Char {
+	return append(dst, t[start:end]...)
+}
+
+// Slice slices the array.
+func (t Array) Slice(start, end int) gollab.TokenArray {
+	return t[start:end]
+}
+
+// Len returns the length of the array.
+func (t Array) Len() int {
+	return len(t)
+}
+
+// String returns the text of the Array without its attributes.
+func (t Array) String() string {
+	var b strings.Builder
+	for _, c := range t {
+		b.WriteRune(c.Rune)
+	}
+	return b.String()
+}
+
+// Runs groups the Chars of the Array into runs of text sharing the same attributes.
+func (t Array) Runs() []Run {
+	var runs []Run
+	var b strings.Builder
+	for i, c := range t {
+		b.WriteRune(c.Rune)
+		if i == len(t)-1 || !t[i+1].Attributes.Equal(c.Attributes) {
+			runs = append(runs, Run{Text: b.String(), Attributes: c.Attributes})
+			b.Reset()
+		}
+	}
+	return runs
+}
+
+// MarshalJSON encodes the Array as a JSON array of runs.
+func (t Array) MarshalJSON() ([]byte, error) {
+	runs := t.Runs()
+	if runs == nil {
+		runs = []Run{}
+	}
+	return json.Marshal(runs)
+}
+
+// ApplyOp applies an operation to the array using gollab.ApplyTokens, returning the resulting Array. It implements
+// gollab.TokenArrayEditor.
+func (t Array) ApplyOp(op gollab.CompositeOp) (gollab.TokenArray, error) {
+	res, err := gollab.ApplyTokens(op, []Char(t))
+	if err != nil {
+		return nil, err
+	}
+	return Array(res), nil
+}
+
+// ArrayType contains methods related to Array.
+type ArrayType struct{}
+
+// NewBuilder creates a new ArrayBuilder.
+func (ArrayType) NewBuilder() gollab.TokenArrayBuilder {
+	return &ArrayBuilder{
+		Array: []Char{},
+	}
+}
+
+// Concat concatenates two TokenArrays and returns the result.
+func (ArrayType) Concat(a, b gollab.TokenArray) gollab.TokenArray {
+	ra, ok := a.(Array)
+	if !ok {
+		panic("ArrayType.Concat: expected param a to be of type Array")
+	}
+	rb, ok := b.(Array)
+	if !ok {
+		panic("ArrayType.Concat: expected param b to be of type Array")
+	}
+
+	newTokens := make(Array, len(ra)+len(rb))
+	copy(newTokens, ra)
+	copy(newTokens[len(ra):], rb)
+	return newTokens
+}
+
+// UnmarshalTokenArray decodes an Array from a JSON array of runs. A plain JSON string is accepted too, in which case
+// the text has no attributes. It implements gollab.TokenArrayUnmarshaler.
+func (ArrayType) UnmarshalTokenArray(data []byte) (gollab.TokenArray, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return New(s, nil), nil
+	}
+
+	var runs []Run
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, err
+	}
+	return FromRuns(runs), nil
+}
+
+// ArrayBuilder is a TokenArrayBuilder implementation using Array.
+type ArrayBuilder struct {
+	Array Array
+}
+
+// Write appends a given Char to the builder's Array. It implements gollab.TypedTokenArrayBuilder.
+func (b *ArrayBuilder) Write(c Char) error {
+	b.Array = append(b.Array, c)
+	return nil
+}
+
+// WriteToken appends a given token to the builder's Array. The token has to be either a Char or a rune, which is
+// written without attributes.
+func (b *ArrayBuilder) WriteToken(token interface{}) error {
+	switch token := token.(type) {
+	case Char:
+		return b.Write(token)
+	case rune:
+		return b.Write(Char{Rune: token})
+	}
+	return errors.New("ArrayBuilder.WriteToken: expected a Char or a rune")
+}
+
+// TokenArray returns the Array.
+func (b ArrayBuilder) TokenArray() gollab.TokenArray {
+	return b.Array
+}
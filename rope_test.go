@@ -0,0 +1,106 @@
+package gollab_test
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/ropetoken"
+	"github.com/danielslee/gollab/runetoken"
+)
+
+func TestRopeSliceAndConcat(t *testing.T) {
+	s := []rune(strings.Repeat("안녕 hello world ", 300))
+	rope := ropetoken.FromRunes(s)
+
+	if rope.Len() != len(s) {
+		t.Fatalf("expected length %d, got %d", len(s), rope.Len())
+	}
+	for i := 0; i < 1000; i++ {
+		idx := rand.Intn(len(s))
+		if rope.At(idx) != s[idx] {
+			t.Fatalf("At(%d): expected %q, got %q", idx, s[idx], rope.At(idx))
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		start := rand.Intn(len(s))
+		end := start + rand.Intn(len(s)-start+1)
+		sliced := rope.Slice(start, end).(ropetoken.Rope)
+		if sliced.String() != string(s[start:end]) {
+			t.Fatalf("Slice(%d, %d) returned a wrong result", start, end)
+		}
+
+		joined := ropetoken.RopeType{}.Concat(rope.Slice(0, start), rope.Slice(start, len(s))).(ropetoken.Rope)
+		if joined.String() != string(s) {
+			t.Fatalf("Concat of slices split at %d returned a wrong result", start)
+		}
+	}
+}
+
+func TestRopeApplyOp(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+			base := randString(op.InputLength())
+
+			expected, err := runetoken.ApplyToString(op, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			applied, err := ropetoken.New(base).ApplyOp(op)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := applied.(ropetoken.Rope).String(); s != expected {
+				t.Errorf("expected %q, got %q", expected, s)
+			}
+
+			builder := ropetoken.RopeType{}.NewBuilder()
+			if err := op.Apply(gollab.NewTokenArrayReader(ropetoken.New(base)), builder); err != nil {
+				t.Fatal(err)
+			}
+			if s := builder.TokenArray().(ropetoken.Rope).String(); s != expected {
+				t.Errorf("expected %q from CompositeOp.Apply, got %q", expected, s)
+			}
+		})
+	}
+}
+
+func TestRopeApplyOpLarge(t *testing.T) {
+	base := []rune(strings.Repeat("lorem ipsum dolor sit amet ", 40000))
+	rope := ropetoken.FromRunes(base)
+
+	for i := 0; i < 200; i++ {
+		pos := rand.Intn(len(base))
+		op := gollab.NewCompositeOp(
+			gollab.Retain{Count: pos},
+			gollab.Insert{Tokens: runetoken.Array("x")},
+			gollab.Delete{Count: 1},
+			gollab.Retain{Count: len(base) - pos - 1},
+		)
+
+		applied, err := rope.ApplyOp(op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rope = applied.(ropetoken.Rope)
+		base[pos] = 'x'
+	}
+
+	if rope.String() != string(base) {
+		t.Error("rope content diverged from the expected content")
+	}
+}
+
+func TestRopeApplyOpLengthMismatch(t *testing.T) {
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 3})
+	if _, err := ropetoken.New("ab").ApplyOp(op); err != gollab.ErrLengthMismatch {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
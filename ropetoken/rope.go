@@ -0,0 +1,353 @@
+/*
+Package ropetoken provides an implementation of the gollab.TokenArray for plain unicode strings backed by a rope - a
+balanced binary tree of rune slices.
+
+Unlike runetoken.Array, a Rope can be sliced and concatenated in O(log n) time without copying its content, which makes
+it suitable for large documents. Ropes are immutable, so slicing or editing a Rope shares most of its nodes with the
+original.
+*/
+package ropetoken
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/danielslee/gollab"
+)
+
+// maxLeafLength is the maximum number of runes stored in a single leaf.
+const maxLeafLength = 512
+
+// node is a node of a rope. A node is either a leaf holding runes, or an inner node with two children.
+type node struct {
+	left, right *node
+	runes       []rune
+	length      int
+	height      int
+}
+
+func height(n *node) int {
+	if n == nil {
+		return -1
+	}
+	return n.height
+}
+
+func length(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return n.length
+}
+
+func newLeaf(runes []rune) *node {
+	if len(runes) == 0 {
+		return nil
+	}
+	return &node{runes: runes, length: len(runes)}
+}
+
+func newNode(left, right *node) *node {
+	h := height(left)
+	if hr := height(right); hr > h {
+		h = hr
+	}
+	return &node{left: left, right: right, length: length(left) + length(right), height: h + 1}
+}
+
+func (n *node) isLeaf() bool {
+	return n.left == nil && n.right == nil
+}
+
+// balance restores the balance of a node whose children's heights differ by at most two.
+func balance(n *node) *node {
+	hl, hr := height(n.left), height(n.right)
+	if hl > hr+1 {
+		l := n.left
+		if height(l.left) >= height(l.right) {
+			return newNode(l.left, newNode(l.right, n.right))
+		}
+		return newNode(newNode(l.left, l.right.left), newNode(l.right.right, n.right))
+	}
+	if hr > hl+1 {
+		r := n.right
+		if height(r.right) >= height(r.left) {
+			return newNode(newNode(n.left, r.left), r.right)
+		}
+		return newNode(newNode(n.left, r.left.left), newNode(r.left.right, r.right))
+	}
+	return n
+}
+
+// join concatenates two ropes, keeping the result balanced.
+func join(l, r *node) *node {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+
+	if l.isLeaf() && r.isLeaf() && l.length+r.length <= maxLeafLength {
+		runes := make([]rune, l.length+r.length)
+		copy(runes, l.runes)
+		copy(runes[l.length:], r.runes)
+		return newLeaf(runes)
+	}
+
+	hl, hr := height(l), height(r)
+	if hl > hr+1 {
+		return balance(newNode(l.left, join(l.right, r)))
+	}
+	if hr > hl+1 {
+		return balance(newNode(join(l, r.left), r.right))
+	}
+	return newNode(l, r)
+}
+
+// slice returns the part of a rope between start and end.
+func slice(n *node, start, end int) *node {
+	if n == nil || start >= end {
+		return nil
+	}
+	if start == 0 && end == n.length {
+		return n
+	}
+	if n.isLeaf() {
+		return newLeaf(n.runes[start:end])
+	}
+
+	l := length(n.left)
+	if end <= l {
+		return slice(n.left, start, end)
+	}
+	if start >= l {
+		return slice(n.right, start-l, end-l)
+	}
+	return join(slice(n.left, start, l), slice(n.right, 0, end-l))
+}
+
+// fromRunes builds a balanced rope out of a rune slice.
+func fromRunes(runes []rune) *node {
+	if len(runes) <= maxLeafLength {
+		return newLeaf(runes)
+	}
+
+	leaves := (len(runes) + maxLeafLength - 1) / maxLeafLength
+	mid := leaves / 2 * maxLeafLength
+	return newNode(fromRunes(runes[:mid]), fromRunes(runes[mid:]))
+}
+
+// walk calls f with the runes of each leaf in order.
+func (n *node) walk(f func(runes []rune)) {
+	if n == nil {
+		return
+	}
+	if n.isLeaf() {
+		f(n.runes)
+		return
+	}
+	n.left.walk(f)
+	n.right.walk(f)
+}
+
+// Rope implements the TokenArray interface as a rope of runes. The zero value is an empty Rope.
+type Rope struct {
+	root *node
+}
+
+// New creates a new Rope holding the given string.
+func New(s string) Rope {
+	return FromRunes([]rune(s))
+}
+
+// FromRunes creates a new Rope holding the given runes. The rune slice must not be modified afterwards.
+func FromRunes(runes []rune) Rope {
+	return Rope{root: fromRunes(runes)}
+}
+
+// Type returns the RopeType.
+func (Rope) Type() gollab.TokenArrayType {
+	return RopeType{}
+}
+
+// At returns an element given its index.
+func (r Rope) At(idx int) interface{} {
+	return r.TokenAt(idx)
+}
+
+// TokenAt returns an element given its index. It implements gollab.TypedTokenArray.
+func (r Rope) TokenAt(idx int) rune {
+	n := r.root
+	for !n.isLeaf() {
+		if l := length(n.left); idx < l {
+			n = n.left
+		} else {
+			idx -= l
+			n = n.right
+		}
+	}
+	return n.runes[idx]
+}
+
+// Slice slices the rope.
+func (r Rope) Slice(start, end int) gollab.TokenArray {
+	if start < 0 || end > r.Len() || start > end {
+		panic("Rope.Slice: slice bounds out of range")
+	}
+	return Rope{root: slice(r.root, start, end)}
+}
+
+// Len returns the length of the rope.
+func (r Rope) Len() int {
+	return length(r.root)
+}
+
+// AppendTokens appends the runes between start and end to dst. It implements gollab.TypedTokenArray.
+func (r Rope) AppendTokens(dst []rune, start, end int) []rune {
+	slice(r.root, start, end).walk(func(leaf []rune) {
+		dst = append(dst, leaf...)
+	})
+	return dst
+}
+
+// Runes returns the content of the rope as a rune slice.
+func (r Rope) Runes() []rune {
+	return r.AppendTokens(make([]rune, 0, r.Len()), 0, r.Len())
+}
+
+// String returns the content of the rope as a string.
+func (r Rope) String() string {
+	var b strings.Builder
+	b.Grow(r.Len())
+	r.root.walk(func(leaf []rune) {
+		for _, c := range leaf {
+			b.WriteRune(c)
+		}
+	})
+	return b.String()
+}
+
+// MarshalJSON encodes the Rope as a JSON string.
+func (r Rope) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// MarshalBinary encodes the Rope as UTF-8.
+func (r Rope) MarshalBinary() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// ApplyOp applies an operation to the rope, returning the resulting Rope.
+//
+// Retained ranges are sliced out of the rope and concatenated with the inserted tokens, so applying an operation takes
+// O(k log n) time, k being the number of PrimitiveOps in the operation, as opposed to the O(n) time it takes to read
+// and write every token using CompositeOp.Apply. The operation is checked using gollab.CompositeOp.ValidateApply first.
+// It implements gollab.TokenArrayEditor.
+func (r Rope) ApplyOp(op gollab.CompositeOp) (gollab.TokenArray, error) {
+	if err := op.ValidateApply(r.Len()); err != nil {
+		return nil, err
+	}
+
+	var res *node
+	var pos int
+	for _, p := range op {
+		switch p := p.(type) {
+		case gollab.Retain:
+			res = join(res, slice(r.root, pos, pos+p.Count))
+			pos += p.Count
+		case gollab.Delete:
+			pos += p.Count
+		case gollab.Insert:
+			inserted, err := toNode(p.Tokens)
+			if err != nil {
+				return nil, err
+			}
+			res = join(res, inserted)
+		}
+	}
+	return Rope{root: res}, nil
+}
+
+var errExpectedRune = errors.New("expected a rune")
+
+// toNode converts a TokenArray of runes to a rope.
+func toNode(t gollab.TokenArray) (*node, error) {
+	switch t := t.(type) {
+	case Rope:
+		return t.root, nil
+	case gollab.TypedTokenArray[rune]:
+		return fromRunes(t.AppendTokens(make([]rune, 0, t.Len()), 0, t.Len())), nil
+	}
+
+	runes := make([]rune, t.Len())
+	for i := range runes {
+		r, ok := t.At(i).(rune)
+		if !ok {
+			return nil, errExpectedRune
+		}
+		runes[i] = r
+	}
+	return fromRunes(runes), nil
+}
+
+// RopeType contains methods related to Rope.
+type RopeType struct{}
+
+// NewBuilder creates a new RopeBuilder.
+func (RopeType) NewBuilder() gollab.TokenArrayBuilder {
+	return &RopeBuilder{}
+}
+
+// Concat concatenates two TokenArrays and returns the result. Both have to be TokenArrays of runes.
+func (RopeType) Concat(a, b gollab.TokenArray) gollab.TokenArray {
+	an, err := toNode(a)
+	if err != nil {
+		panic("RopeType.Concat: expected param a to contain runes")
+	}
+	bn, err := toNode(b)
+	if err != nil {
+		panic("RopeType.Concat: expected param b to contain runes")
+	}
+	return Rope{root: join(an, bn)}
+}
+
+// UnmarshalTokenArray decodes a Rope from a JSON string. It implements gollab.TokenArrayUnmarshaler.
+func (RopeType) UnmarshalTokenArray(data []byte) (gollab.TokenArray, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return New(s), nil
+}
+
+// UnmarshalTokenArrayBinary decodes a Rope from UTF-8. It implements gollab.TokenArrayBinaryUnmarshaler.
+func (RopeType) UnmarshalTokenArrayBinary(data []byte) (gollab.TokenArray, error) {
+	return New(string(data)), nil
+}
+
+// RopeBuilder is a TokenArrayBuilder implementation building a Rope.
+type RopeBuilder struct {
+	runes []rune
+}
+
+// Write appends a given rune to the builder. It implements gollab.TypedTokenArrayBuilder.
+func (b *RopeBuilder) Write(r rune) error {
+	b.runes = append(b.runes, r)
+	return nil
+}
+
+// WriteToken appends a given token to the builder.
+func (b *RopeBuilder) WriteToken(token interface{}) error {
+	r, ok := token.(rune)
+	if !ok {
+		return errors.New("RopeBuilder.WriteToken: expected a rune")
+	}
+	return b.Write(r)
+}
+
+// TokenArray returns the built Rope.
+func (b *RopeBuilder) TokenArray() gollab.TokenArray {
+	return FromRunes(b.runes)
+}
@@ -0,0 +1,176 @@
+package runetoken
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/danielslee/gollab"
+)
+
+// ErrInvalidOffset is returned when an offset is out of range or points inside an encoded rune, such as between the
+// two halves of a UTF-16 surrogate pair.
+var ErrInvalidOffset = errors.New("invalid offset")
+
+// utf16Len returns the number of UTF-16 code units needed to encode a rune. Invalid runes are encoded as U+FFFD.
+func utf16Len(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+// utf8Len returns the number of bytes needed to encode a rune as UTF-8. Invalid runes are encoded as U+FFFD.
+func utf8Len(r rune) int {
+	if n := utf8.RuneLen(r); n > 0 {
+		return n
+	}
+	return utf8.RuneLen(utf8.RuneError)
+}
+
+// toOffset converts a rune index into an offset in units of the given encoding.
+func toOffset(t Array, idx int, unitLen func(rune) int) (int, error) {
+	if idx < 0 || idx > len(t) {
+		return 0, ErrInvalidOffset
+	}
+
+	var offset int
+	for _, r := range t[:idx] {
+		offset += unitLen(r)
+	}
+	return offset, nil
+}
+
+// fromOffset converts an offset in units of the given encoding into a rune index.
+func fromOffset(t Array, offset int, unitLen func(rune) int) (int, error) {
+	if offset < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	var units int
+	for idx, r := range t {
+		if units == offset {
+			return idx, nil
+		}
+		if units += unitLen(r); units > offset {
+			return 0, ErrInvalidOffset
+		}
+	}
+	if units != offset {
+		return 0, ErrInvalidOffset
+	}
+	return len(t), nil
+}
+
+// UTF16Len returns the length of the array in UTF-16 code units.
+func (t Array) UTF16Len() int {
+	l, _ := toOffset(t, len(t), utf16Len)
+	return l
+}
+
+// RuneToUTF16Offset converts a rune index into t into the equivalent UTF-16 code unit offset, as used by JavaScript
+// strings.
+func RuneToUTF16Offset(t Array, idx int) (int, error) {
+	return toOffset(t, idx, utf16Len)
+}
+
+// UTF16ToRuneOffset converts a UTF-16 code unit offset into t into the equivalent rune index. ErrInvalidOffset is
+// returned if the offset points between the two halves of a surrogate pair.
+func UTF16ToRuneOffset(t Array, offset int) (int, error) {
+	return fromOffset(t, offset, utf16Len)
+}
+
+// RuneToByteOffset converts a rune index into t into the equivalent byte offset into its UTF-8 encoding.
+func RuneToByteOffset(t Array, idx int) (int, error) {
+	return toOffset(t, idx, utf8Len)
+}
+
+// ByteToRuneOffset converts a byte offset into the UTF-8 encoding of t into the equivalent rune index.
+// ErrInvalidOffset is returned if the offset points inside the encoding of a rune.
+func ByteToRuneOffset(t Array, offset int) (int, error) {
+	return fromOffset(t, offset, utf8Len)
+}
+
+// OpFromUTF16 converts an operation whose Retain and Delete counts are expressed in UTF-16 code units, as computed by
+// JavaScript editors, into an equivalent operation counting runes. base is the document the operation applies to.
+//
+// Inserted tokens are kept as they are. ErrInvalidOffset is returned if a count ends inside a surrogate pair and
+// gollab.ErrLengthMismatch if the operation doesn't span the whole document.
+func OpFromUTF16(op gollab.CompositeOp, base Array) (gollab.CompositeOp, error) {
+	ops := make([]gollab.PrimitiveOp, 0, len(op))
+	var idx int
+	for _, p := range op {
+		var count int
+		switch p := p.(type) {
+		case gollab.Retain:
+			count = p.Count
+		case gollab.Delete:
+			count = p.Count
+		default:
+			ops = append(ops, p)
+			continue
+		}
+
+		if count < 0 {
+			return nil, gollab.ErrInvalidCount
+		}
+		var units, runes int
+		for units < count {
+			if idx+runes >= len(base) {
+				return nil, gollab.ErrLengthMismatch
+			}
+			units += utf16Len(base[idx+runes])
+			runes++
+		}
+		if units != count {
+			return nil, ErrInvalidOffset
+		}
+		idx += runes
+
+		if r, ok := p.(gollab.Retain); ok {
+			ops = append(ops, gollab.Retain{Count: runes, Attributes: r.Attributes})
+		} else {
+			ops = append(ops, gollab.Delete{Count: runes})
+		}
+	}
+
+	if idx != len(base) {
+		return nil, gollab.ErrLengthMismatch
+	}
+	return gollab.NewCompositeOp(ops...), nil
+}
+
+// OpToUTF16 converts an operation counting runes into an equivalent operation whose Retain and Delete counts are
+// expressed in UTF-16 code units. base is the document the operation applies to. It is the inverse of OpFromUTF16.
+func OpToUTF16(op gollab.CompositeOp, base Array) (gollab.CompositeOp, error) {
+	if op.InputLength() != len(base) {
+		return nil, gollab.ErrLengthMismatch
+	}
+
+	ops := make([]gollab.PrimitiveOp, 0, len(op))
+	var idx int
+	for _, p := range op {
+		var count int
+		switch p := p.(type) {
+		case gollab.Retain:
+			count = p.Count
+		case gollab.Delete:
+			count = p.Count
+		default:
+			ops = append(ops, p)
+			continue
+		}
+
+		if count < 0 || idx+count > len(base) {
+			return nil, gollab.ErrInvalidCount
+		}
+		units, _ := toOffset(base[idx:idx+count], count, utf16Len)
+		idx += count
+
+		if r, ok := p.(gollab.Retain); ok {
+			ops = append(ops, gollab.Retain{Count: units, Attributes: r.Attributes})
+		} else {
+			ops = append(ops, gollab.Delete{Count: units})
+		}
+	}
+	return gollab.NewCompositeOp(ops...), nil
+}
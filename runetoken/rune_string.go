@@ -1,6 +1,12 @@
 /*
 Package runetoken provides an implementation of the gollab.TokenReader, gollab.TokenWriter and gollab.TokenArray
 for plain unicode strings.
+
+It also provides helpers for computing operations from plain strings, either by diffing them (see Diff) or by parsing a
+unified diff (see ParseUnifiedDiff), and for rendering operations as unified diffs (see FormatUnifiedDiff).
+
+Operations count runes, while browser editors count UTF-16 code units. RuneToUTF16Offset, UTF16ToRuneOffset and their
+UTF-8 byte offset counterparts convert single offsets, and OpFromUTF16 and OpToUTF16 convert whole operations.
  */
 package runetoken
 
@@ -19,6 +25,16 @@ func (Array) Type() gollab.TokenArrayType {
 	return ArrayType{}
 }
 
+// ApplyOp applies an operation to the array using gollab.ApplyTokens, returning the resulting Array. It implements
+// gollab.TokenArrayEditor.
+func (t Array) ApplyOp(op gollab.CompositeOp) (gollab.TokenArray, error) {
+	res, err := gollab.ApplyTokens(op, []rune(t))
+	if err != nil {
+		return nil, err
+	}
+	return Array(res), nil
+}
+
 // ArrayType contains methods related to Array.
 type ArrayType struct{}
 
@@ -46,19 +62,38 @@ func (ArrayType) Concat(a, b gollab.TokenArray) gollab.TokenArray {
 	return newTokens
 }
 
+// UnmarshalTokenArray decodes an Array from a JSON string. It implements gollab.TokenArrayUnmarshaler.
+func (ArrayType) UnmarshalTokenArray(data []byte) (gollab.TokenArray, error) {
+	var t Array
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// UnmarshalTokenArrayBinary decodes an Array from UTF-8. It implements gollab.TokenArrayBinaryUnmarshaler.
+func (ArrayType) UnmarshalTokenArrayBinary(data []byte) (gollab.TokenArray, error) {
+	return Array(string(data)), nil
+}
+
 // ArrayBuilder is a TokenArrayBuilder implementation using Array.
 type ArrayBuilder struct {
 	Array Array
 }
 
+// Write appends a given rune to the builder's Array. It implements gollab.TypedTokenArrayBuilder.
+func (b *ArrayBuilder) Write(r rune) error {
+	b.Array = append(b.Array, r)
+	return nil
+}
+
 // WriteToken appends a given token to the builder's Array.
 func (b *ArrayBuilder) WriteToken(token interface{}) error {
 	r, ok := token.(rune)
 	if !ok {
 		return errors.New("ArrayBuilder.WriteToken: expected a rune")
 	}
-	b.Array = append(b.Array, r)
-	return nil
+	return b.Write(r)
 }
 
 // TokenArray returns the Array.
@@ -71,6 +106,16 @@ func (t Array) At(idx int) interface{} {
 	return t[idx]
 }
 
+// TokenAt returns an element given its index. It implements gollab.TypedTokenArray.
+func (t Array) TokenAt(idx int) rune {
+	return t[idx]
+}
+
+// AppendTokens appends the runes between start and end to dst. It implements gollab.TypedTokenArray.
+func (t Array) AppendTokens(dst []rune, start, end int) []rune {
+	return append(dst, t[start:end]...)
+}
+
 // Slice slices the array.
 func (t Array) Slice(start, end int) gollab.TokenArray {
 	return t[start:end]
@@ -101,6 +146,11 @@ func (t *Array) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalBinary encodes the Array as UTF-8.
+func (t Array) MarshalBinary() ([]byte, error) {
+	return []byte(string(t)), nil
+}
+
 // StringReader implements a TokenReader using a strings.Reader, which can be created using a plain Go string.
 type StringReader struct {
 	Reader *strings.Reader
@@ -142,3 +192,11 @@ func ApplyToString(op gollab.Op, text string) (string, error) {
 	}
 	return out.String(), nil
 }
+
+// Diff computes an operation turning the string old into new. It works like gollab.Diff, comparing runes directly.
+func Diff(old, new string) gollab.CompositeOp {
+	a, b := Array(old), Array(new)
+	return gollab.DiffFunc(a, b, func(i, j int) bool {
+		return a[i] == b[j]
+	})
+}
@@ -0,0 +1,397 @@
+package runetoken
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/danielslee/gollab"
+)
+
+// ErrInvalidUnifiedDiff is returned when a unified diff cannot be parsed.
+var ErrInvalidUnifiedDiff = errors.New("invalid unified diff")
+
+// ErrHunkNotFound is returned when a hunk of a unified diff cannot be located within the document.
+var ErrHunkNotFound = errors.New("hunk not found")
+
+// MaxUnifiedDiffFuzz is the maximum number of leading and trailing context lines ParseUnifiedDiff ignores when a hunk
+// cannot be located within the document otherwise.
+const MaxUnifiedDiffFuzz = 2
+
+const noNewlineMarker = `\ No newline at end of file`
+
+// splitLines splits a text into lines, each including its trailing newline (if any).
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLine is a line of a hunk prefixed by one of ' ', '-' or '+'.
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// FormatUnifiedDiff renders the changes an operation makes to base as a line-oriented unified diff, with the given
+// number of context lines around each change. fromFile and toFile are used as file names in the diff's header.
+//
+// The lines are diffed using gollab.Diff, so the diff shows the minimal line changes between base and the result of
+// the operation.
+func FormatUnifiedDiff(op gollab.CompositeOp, base Array, fromFile, toFile string, context int) (string, error) {
+	newText, err := ApplyToString(op, base.String())
+	if err != nil {
+		return "", err
+	}
+
+	oldLines, newLines := splitLines(base.String()), splitLines(newText)
+
+	// map each line to a token so lines can be diffed as an Array
+	ids := make(map[string]rune)
+	toIDs := func(lines []string) Array {
+		a := make(Array, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = rune(len(ids))
+				ids[l] = id
+			}
+			a[i] = id
+		}
+		return a
+	}
+	oldIDs, newIDs := toIDs(oldLines), toIDs(newLines)
+	lineOp := gollab.DiffFunc(oldIDs, newIDs, func(i, j int) bool {
+		return oldIDs[i] == newIDs[j]
+	})
+
+	// inserted lines are placed after deleted ones, as is customary in unified diffs
+	var lines, inserted []diffLine
+	var i, j int
+	for _, p := range lineOp {
+		switch p := p.(type) {
+		case gollab.Retain:
+			lines = append(lines, inserted...)
+			inserted = nil
+			for ; p.Count > 0; p.Count-- {
+				lines = append(lines, diffLine{' ', oldLines[i]})
+				i++
+				j++
+			}
+		case gollab.Delete:
+			for ; p.Count > 0; p.Count-- {
+				lines = append(lines, diffLine{'-', oldLines[i]})
+				i++
+			}
+		case gollab.Insert:
+			for k := 0; k < p.Tokens.Len(); k++ {
+				inserted = append(inserted, diffLine{'+', newLines[j]})
+				j++
+			}
+		}
+	}
+	lines = append(lines, inserted...)
+
+	var b strings.Builder
+	var oldLine, newLine int
+	for start := 0; start < len(lines); {
+		// find the next change
+		for start < len(lines) && lines[start].kind == ' ' {
+			start++
+			oldLine++
+			newLine++
+		}
+		if start == len(lines) {
+			break
+		}
+
+		// extend the hunk until there are more than 2*context unchanged lines
+		end := start
+		for unchanged := 0; end < len(lines) && unchanged <= 2*context; end++ {
+			if lines[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		for end > start && lines[end-1].kind == ' ' {
+			end--
+		}
+
+		hunkStart := start - context
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := end + context
+		if hunkEnd > len(lines) {
+			hunkEnd = len(lines)
+		}
+
+		oldStart, newStart := oldLine-(start-hunkStart), newLine-(start-hunkStart)
+		var oldCount, newCount int
+		for _, l := range lines[hunkStart:hunkEnd] {
+			if l.kind != '+' {
+				oldCount++
+			}
+			if l.kind != '-' {
+				newCount++
+			}
+		}
+
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromFile, toFile)
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", formatRange(oldStart, oldCount), formatRange(newStart, newCount))
+		for _, l := range lines[hunkStart:hunkEnd] {
+			b.WriteByte(l.kind)
+			if strings.HasSuffix(l.text, "\n") {
+				b.WriteString(l.text)
+			} else {
+				b.WriteString(l.text)
+				b.WriteString("\n" + noNewlineMarker + "\n")
+			}
+		}
+
+		for _, l := range lines[start:hunkEnd] {
+			if l.kind != '+' {
+				oldLine++
+			}
+			if l.kind != '-' {
+				newLine++
+			}
+		}
+		start = hunkEnd
+	}
+
+	return b.String(), nil
+}
+
+// formatRange formats a hunk range given its zero-based start line and line count.
+func formatRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return strconv.Itoa(start + 1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+// hunk is a parsed hunk of a unified diff.
+type hunk struct {
+	oldStart int
+	lines    []diffLine
+}
+
+// oldLines returns the lines the hunk expects to find in the document.
+func (h hunk) oldLines() (lines []string) {
+	for _, l := range h.lines {
+		if l.kind != '+' {
+			lines = append(lines, l.text)
+		}
+	}
+	return
+}
+
+// ParseUnifiedDiff parses a unified diff, such as one produced by FormatUnifiedDiff or git diff, into an operation
+// which can be applied to base. The diff must describe changes to a single file.
+//
+// Just like the patch utility, hunks are located within base using their context rather than relying only on their
+// line numbers, so they can be applied to a document which has been modified since the diff was made. If a hunk cannot
+// be located, up to MaxUnifiedDiffFuzz leading and trailing context lines are ignored. ErrHunkNotFound is returned if
+// a hunk cannot be located at all.
+func ParseUnifiedDiff(diff string, base Array) (gollab.CompositeOp, error) {
+	hunks, err := parseHunks(diff)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := splitLines(base.String())
+	offsets := make([]int, len(lines)+1)
+	for i, l := range lines {
+		offsets[i+1] = offsets[i] + len([]rune(l))
+	}
+
+	var ops []gollab.PrimitiveOp
+	var line, drift int
+	for i, h := range hunks {
+		start, body, ok := locateHunk(h, lines, line, h.oldStart+drift)
+		if !ok {
+			return nil, fmt.Errorf("%w: hunk %d", ErrHunkNotFound, i+1)
+		}
+		drift = start - h.oldStart
+
+		ops = append(ops, gollab.Retain{Count: offsets[start] - offsets[line]})
+		line = start
+		for _, l := range body {
+			switch l.kind {
+			case ' ':
+				ops = append(ops, gollab.Retain{Count: len([]rune(l.text))})
+				line++
+			case '-':
+				ops = append(ops, gollab.Delete{Count: len([]rune(l.text))})
+				line++
+			case '+':
+				ops = append(ops, gollab.Insert{Tokens: Array(l.text)})
+			}
+		}
+	}
+	ops = append(ops, gollab.Retain{Count: offsets[len(lines)] - offsets[line]})
+
+	var nonEmpty []gollab.PrimitiveOp
+	for _, op := range ops {
+		if op.InputLength() > 0 || op.OutputLength() > 0 {
+			nonEmpty = append(nonEmpty, op)
+		}
+	}
+	return gollab.NewCompositeOp(nonEmpty...), nil
+}
+
+// locateHunk finds the line at which a hunk applies, starting at or after minLine and as close as possible to
+// expected. It returns the hunk's lines with any context ignored due to fuzz removed.
+func locateHunk(h hunk, lines []string, minLine, expected int) (start int, body []diffLine, ok bool) {
+	var leading, trailing int
+	for leading < len(h.lines) && h.lines[leading].kind == ' ' {
+		leading++
+	}
+	for trailing < len(h.lines)-leading && h.lines[len(h.lines)-trailing-1].kind == ' ' {
+		trailing++
+	}
+
+	for fuzz := 0; fuzz <= MaxUnifiedDiffFuzz; fuzz++ {
+		skipLeading, skipTrailing := min(fuzz, leading), min(fuzz, trailing)
+		body = h.lines[skipLeading : len(h.lines)-skipTrailing]
+		old := hunk{lines: body}.oldLines()
+
+		first, last := expected+skipLeading, len(lines)-len(old)
+		for delta := 0; first-delta >= minLine || first+delta <= last; delta++ {
+			for _, start := range []int{first + delta, first - delta} {
+				if start >= minLine && start <= last && linesMatch(lines[start:], old) {
+					return start, body, true
+				}
+			}
+		}
+	}
+	return 0, nil, false
+}
+
+func linesMatch(lines, expected []string) bool {
+	for i, e := range expected {
+		if lines[i] != e {
+			return false
+		}
+	}
+	return true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// parseHunks parses the hunks of a unified diff, skipping any headers.
+func parseHunks(diff string) ([]hunk, error) {
+	var hunks []hunk
+	lines := splitLines(diff)
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "@@") {
+			if strings.HasPrefix(lines[i], "--- ") && len(hunks) > 0 {
+				return nil, fmt.Errorf("%w: multiple files", ErrInvalidUnifiedDiff)
+			}
+			continue
+		}
+
+		h, oldCount, newCount, err := parseHunkHeader(lines[i])
+		if err != nil {
+			return nil, err
+		}
+
+		for oldCount > 0 || newCount > 0 {
+			i++
+			if i >= len(lines) {
+				return nil, fmt.Errorf("%w: unexpected end of hunk", ErrInvalidUnifiedDiff)
+			}
+
+			l := lines[i]
+			if l == "\n" || l == "" {
+				// some tools strip the space of empty context lines
+				l = " " + l
+			}
+			if !strings.HasSuffix(l, "\n") {
+				l += "\n"
+			}
+
+			kind := l[0]
+			switch kind {
+			case ' ':
+				oldCount--
+				newCount--
+			case '-':
+				oldCount--
+			case '+':
+				newCount--
+			default:
+				return nil, fmt.Errorf("%w: unexpected line %q", ErrInvalidUnifiedDiff, lines[i])
+			}
+			if oldCount < 0 || newCount < 0 {
+				return nil, fmt.Errorf("%w: hunk longer than its header", ErrInvalidUnifiedDiff)
+			}
+
+			h.lines = append(h.lines, diffLine{kind, l[1:]})
+			if i+1 < len(lines) && strings.HasPrefix(lines[i+1], `\`) {
+				h.lines[len(h.lines)-1].text = strings.TrimSuffix(l[1:], "\n")
+				i++
+			}
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks, nil
+}
+
+// parseHunkHeader parses a hunk header of the form "@@ -l,s +l,s @@".
+func parseHunkHeader(header string) (h hunk, oldCount, newCount int, err error) {
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") ||
+		!strings.HasPrefix(fields[2], "+") {
+		return h, 0, 0, fmt.Errorf("%w: invalid hunk header %q", ErrInvalidUnifiedDiff, header)
+	}
+
+	oldStart, oldCount, err := parseRange(fields[1][1:])
+	if err != nil {
+		return h, 0, 0, err
+	}
+	_, newCount, err = parseRange(fields[2][1:])
+	if err != nil {
+		return h, 0, 0, err
+	}
+
+	h.oldStart = oldStart - 1
+	if oldCount == 0 {
+		h.oldStart = oldStart
+	}
+	return h, oldCount, newCount, nil
+}
+
+// parseRange parses a hunk range of the form "l,s" or "l".
+func parseRange(r string) (start, count int, err error) {
+	count = 1
+	if idx := strings.IndexByte(r, ','); idx >= 0 {
+		if count, err = strconv.Atoi(r[idx+1:]); err != nil || count < 0 {
+			return 0, 0, fmt.Errorf("%w: invalid range %q", ErrInvalidUnifiedDiff, r)
+		}
+		r = r[:idx]
+	}
+	if start, err = strconv.Atoi(r); err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("%w: invalid range %q", ErrInvalidUnifiedDiff, r)
+	}
+	return start, count, nil
+}
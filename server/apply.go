@@ -2,12 +2,18 @@ package server
 
 import (
 	"errors"
+	"fmt"
+
 	"github.com/danielslee/gollab"
 )
 
 // ErrUnknownRevision is an error indicating that an unknown revision was encountered.
 var ErrUnknownRevision = errors.New("unknown revision")
 
+// ErrRevisionTooOld is an error indicating that a revision has been dropped from the history according to the
+// RetentionPolicy of a StateStore. The client should resync by rejoining the document.
+var ErrRevisionTooOld = errors.New("revision too old")
+
 // ErrInvalidOperation is an error indication that the operation cannot be applied.
 var ErrInvalidOperation = errors.New("invalid operation")
 
@@ -28,23 +34,37 @@ type ApplyClientOpOutput struct {
 }
 
 // ApplyClientOp Applies a client operation. This function is intended to be used by a StateStore implementation.
+//
+// The operation is validated using gollab.CompositeOp.Validate before being transformed. If the operation is invalid or
+// cannot be transformed or applied, the returned error wraps ErrInvalidOperation.
 func ApplyClientOp(i ApplyClientOpInput) (o ApplyClientOpOutput, err error) {
+	docLen := i.CurrentDocument.Len()
+	if len(i.TransformOps) > 0 {
+		docLen = i.TransformOps[0].InputLength()
+	}
+	if err = i.Op.Validate(docLen); err != nil {
+		err = fmt.Errorf("%w: %v", ErrInvalidOperation, err)
+		return
+	}
+
 	o.Op = i.Op
 	for _, transformOp := range i.TransformOps {
-		if o.Op.InputLength() != transformOp.InputLength() {
-			err = ErrInvalidOperation
+		o.Op, _, err = o.Op.TryTransform(transformOp)
+		if err != nil {
+			err = fmt.Errorf("%w: %v", ErrInvalidOperation, err)
 			return
 		}
-		o.Op, _ = o.Op.Transform(transformOp)
 	}
 
-	writer := i.CurrentDocument.Type().NewBuilder()
-	err = o.Op.Apply(gollab.NewTokenArrayReader(i.CurrentDocument), writer)
-	if err != nil {
+	if o.Op.InputLength() != i.CurrentDocument.Len() {
+		err = fmt.Errorf("%w: %v", ErrInvalidOperation, gollab.ErrLengthMismatch)
 		return
 	}
 
-	o.Document = writer.TokenArray()
+	o.Document, err = o.Op.ApplyToTokenArray(i.CurrentDocument)
+	if err != nil {
+		return
+	}
 	o.Revision = i.CurrentRevision + 1
 
 	return
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding"
+	"io"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/internal/binenc"
+)
+
+// MarshalBinary encodes the InitMessage into a compact binary form. The document has to implement
+// encoding.BinaryMarshaler.
+func (m InitMessage) MarshalBinary() ([]byte, error) {
+	marshaler, ok := m.Document.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, gollab.ErrNotBinaryMarshaler
+	}
+	document, err := marshaler.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var w binenc.Writer
+	w.WriteBytes(document)
+	w.WriteVarint(int64(m.Revision))
+	w.WriteVarint(int64(len(m.Presence)))
+	for _, p := range m.Presence {
+		w.WriteVarint(int64(p.ClientID))
+		w.WriteBytes([]byte(p.UserID))
+		w.WriteBytes([]byte(p.Name))
+		w.WriteBytes([]byte(p.Color))
+		w.WriteVarint(int64(p.Selection.Anchor))
+		w.WriteVarint(int64(p.Selection.Head))
+		w.WriteVarint(int64(p.Revision))
+	}
+	return w.Bytes(), nil
+}
+
+// UnmarshalInitMessageBinary decodes an InitMessage from its binary form, decoding its document using the given
+// TokenArrayType, which has to implement gollab.TokenArrayBinaryUnmarshaler.
+func UnmarshalInitMessageBinary(data []byte, t gollab.TokenArrayType) (m InitMessage, err error) {
+	r := binenc.NewReader(data)
+
+	document, err := r.ReadBytes()
+	if err != nil {
+		return
+	}
+	if m.Document, err = gollab.UnmarshalTokenArrayBinary(document, t); err != nil {
+		return
+	}
+	if m.Revision, err = r.ReadInt(); err != nil {
+		return
+	}
+
+	presenceCount, err := r.ReadInt()
+	if err != nil {
+		return
+	}
+	if presenceCount < 0 || presenceCount > r.Len() {
+		return m, io.ErrUnexpectedEOF
+	}
+
+	for i := 0; i < presenceCount; i++ {
+		var p PresenceMessage
+		if p.ClientID, err = r.ReadInt(); err != nil {
+			return
+		}
+		if p.UserID, err = r.ReadString(); err != nil {
+			return
+		}
+		if p.Name, err = r.ReadString(); err != nil {
+			return
+		}
+		if p.Color, err = r.ReadString(); err != nil {
+			return
+		}
+		if p.Selection.Anchor, err = r.ReadInt(); err != nil {
+			return
+		}
+		if p.Selection.Head, err = r.ReadInt(); err != nil {
+			return
+		}
+		if p.Revision, err = r.ReadInt(); err != nil {
+			return
+		}
+		m.Presence = append(m.Presence, p)
+	}
+
+	if r.Len() > 0 {
+		return m, gollab.ErrTrailingData
+	}
+	return
+}
+
+// UnmarshalBinary decodes an InitMessage from its binary form.
+//
+// The type of the document cannot be inferred, so gollab.ErrNoTokenArrayType is returned. Use
+// UnmarshalInitMessageBinary to decode the message.
+func (m *InitMessage) UnmarshalBinary(data []byte) error {
+	newM, err := UnmarshalInitMessageBinary(data, nil)
+	if err != nil {
+		return err
+	}
+
+	*m = newM
+	return nil
+}
+
+// MarshalBinary encodes the OpMessage into a compact binary form. See gollab.CompositeOp.MarshalBinary for details
+// on how the operation is encoded.
+func (m OpMessage) MarshalBinary() ([]byte, error) {
+	op, err := m.Op.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var w binenc.Writer
+	w.WriteBytes([]byte(m.AuthorID))
+	w.WriteBytes(op)
+	w.WriteVarint(int64(m.Revision))
+	return w.Bytes(), nil
+}
+
+// UnmarshalOpMessageBinary decodes an OpMessage from its binary form, decoding the tokens of its operation using the
+// given TokenArrayType, which has to implement gollab.TokenArrayBinaryUnmarshaler.
+func UnmarshalOpMessageBinary(data []byte, t gollab.TokenArrayType) (m OpMessage, err error) {
+	r := binenc.NewReader(data)
+
+	if m.AuthorID, err = r.ReadString(); err != nil {
+		return
+	}
+
+	op, err := r.ReadBytes()
+	if err != nil {
+		return
+	}
+	if m.Op, err = gollab.UnmarshalCompositeOpBinary(op, t); err != nil {
+		return
+	}
+
+	if m.Revision, err = r.ReadInt(); err != nil {
+		return
+	}
+
+	if r.Len() > 0 {
+		return m, gollab.ErrTrailingData
+	}
+	return
+}
+
+// UnmarshalBinary decodes an OpMessage from its binary form.
+//
+// The type of tokens cannot be inferred, so gollab.ErrNoTokenArrayType is returned if the operation contains an insert
+// operation. Use UnmarshalOpMessageBinary to decode such operations.
+func (m *OpMessage) UnmarshalBinary(data []byte) error {
+	newM, err := UnmarshalOpMessageBinary(data, nil)
+	if err != nil {
+		return err
+	}
+
+	*m = newM
+	return nil
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/internal/binenc"
+)
+
+// ErrInvalidRange is an error indicating that a range lies outside of the document.
+var ErrInvalidRange = errors.New("invalid range")
+
+// Attribution describes who wrote a token and when. Tokens present in the initial document have the zero Attribution.
+type Attribution struct {
+	AuthorID string    `json:"authorID"`
+	Revision int       `json:"revision"`
+	Time     time.Time `json:"time"`
+}
+
+// Equal reports whether two Attributions are equal.
+func (a Attribution) Equal(b Attribution) bool {
+	return a.AuthorID == b.AuthorID && a.Revision == b.Revision && a.Time.Equal(b.Time)
+}
+
+// BlameSpan is a range of tokens between Start and End sharing the same Attribution.
+type BlameSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+	Attribution
+}
+
+// BlameStore is a StateStore tracking the authorship of the document's tokens.
+type BlameStore interface {
+	StateStore
+	// Blame returns the attribution of the tokens between start and end as consecutive spans.
+	Blame(start, end int) ([]BlameSpan, error)
+}
+
+type attributionSpan struct {
+	length int
+	Attribution
+}
+
+// appendSpan appends a span, merging it with the last one if they share the same Attribution.
+func appendSpan(spans []attributionSpan, s attributionSpan) []attributionSpan {
+	if s.length == 0 {
+		return spans
+	}
+	if n := len(spans); n > 0 && spans[n-1].Attribution.Equal(s.Attribution) {
+		spans[n-1].length += s.length
+		return spans
+	}
+	return append(spans, s)
+}
+
+// Attributions tracks the Attribution of each token of a document as a run-length encoded list of spans. It is updated
+// by applying each operation applied to the document. This type is intended to be used by a StateStore implementation.
+type Attributions struct {
+	spans  []attributionSpan
+	length int
+}
+
+// NewAttributions creates Attributions for an initial document of the given length.
+func NewAttributions(length int) *Attributions {
+	a := &Attributions{length: length}
+	a.spans = appendSpan(nil, attributionSpan{length: length})
+	return a
+}
+
+// Len returns the length of the document.
+func (a *Attributions) Len() int {
+	return a.length
+}
+
+// Apply updates the attributions with an operation, attributing inserted tokens to the given Attribution. Retained
+// tokens keep their attribution even if the operation formats them.
+func (a *Attributions) Apply(op gollab.CompositeOp, attribution Attribution) error {
+	res, err := a.applied(op, attribution)
+	if err != nil {
+		return err
+	}
+	*a = res
+	return nil
+}
+
+// applied returns the attributions resulting from applying an operation, leaving a unchanged.
+func (a *Attributions) applied(op gollab.CompositeOp, attribution Attribution) (Attributions, error) {
+	if op.InputLength() != a.length {
+		return Attributions{}, gollab.ErrLengthMismatch
+	}
+
+	var res []attributionSpan
+	var i, offset int
+	advance := func(n int, keep bool) {
+		for n > 0 {
+			s := a.spans[i]
+			k := s.length - offset
+			if n < k {
+				k = n
+			}
+			if keep {
+				res = appendSpan(res, attributionSpan{length: k, Attribution: s.Attribution})
+			}
+			n -= k
+			offset += k
+			if offset == s.length {
+				i++
+				offset = 0
+			}
+		}
+	}
+
+	for _, p := range op {
+		switch p := p.(type) {
+		case gollab.Retain:
+			advance(p.Count, true)
+		case gollab.Delete:
+			advance(p.Count, false)
+		case gollab.Insert:
+			res = appendSpan(res, attributionSpan{length: p.Tokens.Len(), Attribution: attribution})
+		}
+	}
+
+	return Attributions{spans: res, length: op.OutputLength()}, nil
+}
+
+// Blame returns the attribution of the tokens between start and end as consecutive spans.
+func (a *Attributions) Blame(start, end int) ([]BlameSpan, error) {
+	if start < 0 || start > end || end > a.length {
+		return nil, ErrInvalidRange
+	}
+
+	var res []BlameSpan
+	var pos int
+	for _, s := range a.spans {
+		spanStart, spanEnd := pos, pos+s.length
+		pos = spanEnd
+		if spanEnd <= start {
+			continue
+		}
+		if spanStart >= end {
+			break
+		}
+
+		if spanStart < start {
+			spanStart = start
+		}
+		if spanEnd > end {
+			spanEnd = end
+		}
+		res = append(res, BlameSpan{Start: spanStart, End: spanEnd, Attribution: s.Attribution})
+	}
+	return res, nil
+}
+
+// MarshalBinary encodes the Attributions into a compact binary form.
+func (a *Attributions) MarshalBinary() ([]byte, error) {
+	var w binenc.Writer
+	w.WriteVarint(int64(len(a.spans)))
+	for _, s := range a.spans {
+		w.WriteVarint(int64(s.length))
+		w.WriteBytes([]byte(s.AuthorID))
+		w.WriteVarint(int64(s.Revision))
+		var nanos int64
+		if !s.Time.IsZero() {
+			nanos = s.Time.UnixNano()
+		}
+		w.WriteVarint(nanos)
+	}
+	return w.Bytes(), nil
+}
+
+// UnmarshalBinary decodes Attributions encoded using MarshalBinary.
+func (a *Attributions) UnmarshalBinary(data []byte) error {
+	r := binenc.NewReader(data)
+	n, err := r.ReadInt()
+	if err != nil {
+		return err
+	}
+	if n < 0 || n > r.Len() {
+		return io.ErrUnexpectedEOF
+	}
+
+	spans := make([]attributionSpan, n)
+	var length int
+	for i := range spans {
+		if spans[i].length, err = r.ReadInt(); err != nil {
+			return err
+		}
+		if spans[i].length <= 0 {
+			return gollab.ErrInvalidCount
+		}
+		if spans[i].AuthorID, err = r.ReadString(); err != nil {
+			return err
+		}
+		if spans[i].Revision, err = r.ReadInt(); err != nil {
+			return err
+		}
+		nanos, err := r.ReadVarint()
+		if err != nil {
+			return err
+		}
+		if nanos != 0 {
+			spans[i].Time = time.Unix(0, nanos)
+		}
+		length += spans[i].length
+	}
+	if r.Len() > 0 {
+		return gollab.ErrTrailingData
+	}
+
+	a.spans = spans
+	a.length = length
+	return nil
+}
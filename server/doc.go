@@ -3,7 +3,17 @@ Package server implements a DocumentServer ready to serve a document to any numb
 the document simultaneously and the server will perform all the necessary transformations, broadcasting them to all
 clients.
 
+Clients can also share their selection (or caret) with other clients by sending a PresenceMessage. The server keeps
+the presence of each client up to date as the document changes and sends it to clients joining later.
+
 A custom StateStore can be implemented to use a database or the included MemoryStateStore can be used to store
-everything in memory.
+everything in memory. FileStateStore persists the document to disk in an append-only log with periodic snapshots,
+recovering it after a restart or crash. A RetentionPolicy limits the history kept by either store, rejecting operations
+based on dropped revisions with ErrRevisionTooOld. Both stores implement HistoryStore, giving access to the document and
+the operations at any retained revision, and BlameStore, attributing each token to the author and revision which
+inserted it.
+
+A Hub serves many documents, loading a DocumentServer and StateStore for each document ID when the first client joins it
+and unloading them after an idle timeout.
 */
 package server
@@ -1,16 +1,20 @@
 package server
 
 import (
+	"errors"
 	"log"
+	"sort"
 	"sync"
 
 	"github.com/danielslee/gollab"
 )
 
-// InitMessage is the initial message sent by the server to a new client.
+// InitMessage is the initial message sent by the server to a new client. It carries the presence of all other clients
+// connected at that time.
 type InitMessage struct {
 	Document gollab.TokenArray `json:"document"`
 	Revision int               `json:"revision"`
+	Presence []PresenceMessage `json:"presence"`
 }
 
 // OpMessage is a message containing an operation along with its author's id and revision.
@@ -20,18 +24,51 @@ type OpMessage struct {
 	Revision int                `json:"revision"`
 }
 
-// ClientMessage contains an OpMessage along with its sender's client id.
+// PresenceMessage is a message containing a user's selection (or caret) within the document at a given revision,
+// along with details needed to display it to other users.
+//
+// Clients send a PresenceMessage to the server whenever their selection changes. The server transforms it through all
+// subsequent operations and broadcasts it to all other clients, setting ClientID to the sender's client id.
+type PresenceMessage struct {
+	ClientID  int              `json:"clientID"`
+	UserID    string           `json:"userID"`
+	Name      string           `json:"name"`
+	Color     string           `json:"color"`
+	Selection gollab.Selection `json:"selection"`
+	Revision  int              `json:"revision"`
+}
+
+// PresenceRemovedMessage is a message signifying a client has been removed and its presence should no longer be
+// displayed.
+type PresenceRemovedMessage struct {
+	ClientID int    `json:"clientID"`
+	UserID   string `json:"userID"`
+}
+
+// ClientMessage contains a message along with its sender's client id. It carries the PresenceMessage in Presence if it
+// is set, and the OpMessage in Message otherwise.
 type ClientMessage struct {
 	ClientID int
 	Message  OpMessage
+	Presence *PresenceMessage
 }
 
+// ErrClientTooSlow is the error sent to a client dropped for not keeping up with its messages.
+var ErrClientTooSlow = errors.New("client not keeping up with messages")
+
 // ErrorMessage is a message signifying an error has occurred.
 type ErrorMessage struct {
 	Error string `json:"error"`
 }
 
+// presenceHistoryLength is the number of most recent operations kept by the DocumentServer for transforming
+// PresenceMessages referring to past revisions.
+const presenceHistoryLength = 128
+
 // DocumentServer implements a server serving a single document.
+//
+// By default, sending a message to a client whose channel is full blocks until the client reads its messages. See
+// SetDropSlowClients for dropping such clients instead.
 type DocumentServer struct {
 	state StateStore
 
@@ -40,17 +77,41 @@ type DocumentServer struct {
 	sendChannelsMux sync.RWMutex
 	sendChannels    map[int]chan<- interface{}
 	channelCounter  int
+	// dropSlowClients is guarded by sendChannelsMux
+	dropSlowClients bool
+
+	// presence is guarded by sendChannelsMux
+	presence map[int]PresenceMessage
+	revision int
+	history  []OpMessage
 }
 
 // NewDocumentServer creates a new document server given a StateStore.
 func NewDocumentServer(stateStore StateStore) *DocumentServer {
+	_, revision, err := stateStore.Current()
+	if err != nil {
+		panic(err)
+	}
+
 	return &DocumentServer{
 		state:        stateStore,
 		receiveChan:  make(chan ClientMessage, 128),
 		sendChannels: make(map[int]chan<- interface{}),
+		presence:     make(map[int]PresenceMessage),
+		revision:     revision,
 	}
 }
 
+// SetDropSlowClients sets whether clients not keeping up with their messages are dropped rather than blocking the
+// DocumentServer. A dropped client receives an ErrorMessage with ErrClientTooSlow before its channel is closed, as if it
+// had been removed with RemoveClient.
+func (d *DocumentServer) SetDropSlowClients(drop bool) {
+	d.sendChannelsMux.Lock()
+	defer d.sendChannelsMux.Unlock()
+
+	d.dropSlowClients = drop
+}
+
 // Run start serving clients.
 func (d *DocumentServer) Run() {
 	defer func() {
@@ -69,35 +130,123 @@ func (d *DocumentServer) Run() {
 				return
 			}
 
-			msg := clientMsg.Message
+			if clientMsg.Presence != nil {
+				d.updatePresence(clientMsg.ClientID, *clientMsg.Presence)
+				continue
+			}
 
-			err := d.state.ApplyClient(msg)
-			if err != nil {
+			err := d.state.ApplyClient(clientMsg.Message)
+			if errors.Is(err, ErrRevisionTooOld) {
+				d.sendError(clientMsg.ClientID, ErrRevisionTooOld.Error())
+			} else if err != nil {
 				log.Println("err applying operation:", err)
 				d.sendError(clientMsg.ClientID, "invalid operation")
 			}
 		case op := <-d.state.OperationStream():
+			d.transformPresence(op)
 			d.send(op)
 		}
 	}
 }
 
+func (d *DocumentServer) updatePresence(clientID int, msg PresenceMessage) {
+	d.sendChannelsMux.Lock()
+	defer d.sendChannelsMux.Unlock()
+
+	if _, ok := d.sendChannels[clientID]; !ok {
+		return
+	}
+
+	if msg.Revision > d.revision || msg.Revision < d.revision-len(d.history) {
+		log.Println("dropping presence with unknown revision:", msg.Revision)
+		return
+	}
+
+	for _, op := range d.history[len(d.history)-(d.revision-msg.Revision):] {
+		msg.Selection = msg.Selection.Transform(op.Op, msg.UserID == op.AuthorID)
+	}
+	msg.ClientID = clientID
+	msg.Revision = d.revision
+	d.presence[clientID] = msg
+
+	for id := range d.sendChannels {
+		if id != clientID {
+			d.sendTo(id, msg)
+		}
+	}
+}
+
+func (d *DocumentServer) transformPresence(op OpMessage) {
+	d.sendChannelsMux.Lock()
+	defer d.sendChannelsMux.Unlock()
+
+	if op.Revision <= d.revision {
+		return
+	}
+
+	for id, p := range d.presence {
+		p.Selection = p.Selection.Transform(op.Op, p.UserID == op.AuthorID)
+		p.Revision = op.Revision
+		d.presence[id] = p
+	}
+
+	d.revision = op.Revision
+	d.history = append(d.history, op)
+	if len(d.history) > presenceHistoryLength {
+		d.history = d.history[len(d.history)-presenceHistoryLength:]
+	}
+}
+
+// sendTo sends a message to a client. When dropping slow clients, the last slot of the client's channel is kept for the
+// ErrorMessage sent when dropping it, so that sendTo and sendError never block. It has to be called with
+// sendChannelsMux locked.
+func (d *DocumentServer) sendTo(clientID int, msg interface{}) {
+	c := d.sendChannels[clientID]
+	if d.dropSlowClients && len(c) >= cap(c)-1 {
+		log.Println("dropping client not keeping up with messages:", clientID)
+		c <- ErrorMessage{ErrClientTooSlow.Error()}
+		delete(d.sendChannels, clientID)
+		close(c)
+		d.removePresence(clientID)
+		return
+	}
+	c <- msg
+}
+
+// removePresence clears the presence of a removed client, notifying the remaining clients. It has to be called with
+// sendChannelsMux locked, after the client has been removed from sendChannels.
+func (d *DocumentServer) removePresence(clientID int) {
+	p, ok := d.presence[clientID]
+	if !ok {
+		return
+	}
+
+	delete(d.presence, clientID)
+	for id := range d.sendChannels {
+		d.sendTo(id, PresenceRemovedMessage{
+			ClientID: clientID,
+			UserID:   p.UserID,
+		})
+	}
+}
+
 func (d *DocumentServer) send(msg OpMessage) {
-	d.sendChannelsMux.RLock()
-	defer d.sendChannelsMux.RUnlock()
+	d.sendChannelsMux.Lock()
+	defer d.sendChannelsMux.Unlock()
 
-	for _, c := range d.sendChannels {
-		c <- msg
+	for id := range d.sendChannels {
+		d.sendTo(id, msg)
 	}
 }
 
 func (d *DocumentServer) sendError(clientID int, err string) {
-	d.sendChannelsMux.RLock()
-	defer d.sendChannelsMux.RUnlock()
+	d.sendChannelsMux.Lock()
+	defer d.sendChannelsMux.Unlock()
 	if clientChan, ok := d.sendChannels[clientID]; ok {
 		clientChan <- ErrorMessage{err}
 		delete(d.sendChannels, clientID)
 		close(clientChan)
+		d.removePresence(clientID)
 	}
 }
 
@@ -117,21 +266,31 @@ func (d *DocumentServer) NewClient() (clientID int, sendToClientChan <-chan inte
 		panic(err)
 	}
 
+	presence := make([]PresenceMessage, 0, len(d.presence))
+	for _, p := range d.presence {
+		presence = append(presence, p)
+	}
+	sort.Slice(presence, func(i, j int) bool {
+		return presence[i].ClientID < presence[j].ClientID
+	})
+
 	c <- InitMessage{
 		Document: doc,
 		Revision: rev,
+		Presence: presence,
 	}
 
 	d.sendChannels[clientID] = c
 	return clientID, c
 }
 
-// RemoveClient detaches a client.
+// RemoveClient detaches a client, clearing its presence.
 func (d *DocumentServer) RemoveClient(id int) {
 	d.sendChannelsMux.Lock()
 	defer d.sendChannelsMux.Unlock()
 
 	delete(d.sendChannels, id)
+	d.removePresence(id)
 }
 
 // ReceiveChan returns a channel on which the DocumentServer receiver messages from clients.
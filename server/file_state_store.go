@@ -0,0 +1,579 @@
+package server
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/internal/binenc"
+)
+
+const (
+	fileStateStoreLog      = "log"
+	fileStateStoreSnapshot = "snapshot"
+
+	// recordHeaderLength is the length of the header preceding each record, consisting of the length and the CRC-32
+	// checksum of the record's payload.
+	recordHeaderLength = 8
+)
+
+// ErrCorruptLog is returned when opening a FileStateStore whose snapshot or log is corrupt in a way which cannot be
+// explained by a crash while writing the last record.
+var ErrCorruptLog = errors.New("corrupt log")
+
+// FileStateStore implements a StateStore persisting the document to a directory on disk.
+//
+// Each transformed operation is written, along with its author and revision, to an append-only log and synced to disk
+// before being broadcast, so no acknowledged operation is lost on a crash. Every SnapshotInterval revisions, the whole
+// document is written to a snapshot. When opening the store, the document is rebuilt by loading the latest snapshot and
+// replaying the operations logged after it. A torn record at the end of the log, left by a crash while writing it, is
+// discarded.
+//
+// Along with the current document and its Attributions, the snapshot holds the document at the base revision of the
+// retention policy. The operations preceding it are removed from the log whenever a snapshot is written.
+//
+// The document and the tokens of all operations have to implement encoding.BinaryMarshaler, and their TokenArrayType
+// gollab.TokenArrayBinaryUnmarshaler.
+type FileStateStore struct {
+	mux sync.RWMutex
+
+	dir              string
+	tokenType        gollab.TokenArrayType
+	snapshotInterval int
+	log              *os.File
+	logSize          int64
+	logBase          int
+
+	document         gollab.TokenArray
+	history          opHistory
+	retention        RetentionPolicy
+	attributions     *Attributions
+	snapshotRevision int
+	opStream         chan OpMessage
+}
+
+// OpenFileStateStore opens the FileStateStore stored in dir, creating it with the initial document if it doesn't exist.
+// The type of the initial document is used to decode the stored document and operations. A snapshot is written every
+// snapshotInterval revisions, or never if it isn't positive.
+func OpenFileStateStore(dir string, initial gollab.TokenArray, snapshotInterval int) (*FileStateStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	f := &FileStateStore{
+		dir:              dir,
+		tokenType:        initial.Type(),
+		snapshotInterval: snapshotInterval,
+		document:         initial,
+		history:          opHistory{baseDocument: initial},
+		attributions:     NewAttributions(initial.Len()),
+		opStream:         make(chan OpMessage, 128),
+	}
+
+	if err := f.loadSnapshot(); errors.Is(err, os.ErrNotExist) {
+		if err := f.writeSnapshot(); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(filepath.Join(dir, fileStateStoreLog), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	f.log = log
+
+	if err := f.replayLog(); err != nil {
+		log.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// writeRecord writes a record consisting of a header and the payload.
+func writeRecord(w io.Writer, payload []byte) error {
+	var header [recordHeaderLength]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// encodeLogRecord encodes the payload of a log record holding an operation applied at the given time.
+func encodeLogRecord(opMsg OpMessage, t time.Time) ([]byte, error) {
+	op, err := opMsg.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var w binenc.Writer
+	w.WriteVarint(t.UnixNano())
+	w.WriteBytes(op)
+	return w.Bytes(), nil
+}
+
+// decodeLogRecord decodes the payload of a log record encoded by encodeLogRecord.
+func decodeLogRecord(payload []byte, tokenType gollab.TokenArrayType) (opMsg OpMessage, t time.Time, err error) {
+	r := binenc.NewReader(payload)
+	nanos, err := r.ReadVarint()
+	if err != nil {
+		return
+	}
+	op, err := r.ReadBytes()
+	if err != nil {
+		return
+	}
+	opMsg, err = UnmarshalOpMessageBinary(op, tokenType)
+	return opMsg, time.Unix(0, nanos), err
+}
+
+// readRecord reads a record written by writeRecord. It returns io.EOF if there are no more records, and
+// io.ErrUnexpectedEOF if the header or the payload of the record is cut short by the end of the data, as left by a crash
+// while writing it. A complete record whose checksum doesn't match returns ErrCorruptLog.
+func readRecord(r *bytes.Reader) ([]byte, error) {
+	if r.Len() == 0 {
+		return nil, io.EOF
+	}
+	if r.Len() < recordHeaderLength {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	var header [recordHeaderLength]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[:4])
+	if uint64(length) > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, ErrCorruptLog
+	}
+	return payload, nil
+}
+
+// syncDir syncs a directory, making sure renames and newly created files within it are persisted.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// loadSnapshot loads the current document with its Attributions and the base document, along with their revisions,
+// from the snapshot.
+func (f *FileStateStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(f.dir, fileStateStoreSnapshot))
+	if err != nil {
+		return err
+	}
+
+	payload, err := readRecord(bytes.NewReader(data))
+	if err != nil {
+		return ErrCorruptLog
+	}
+
+	r := binenc.NewReader(payload)
+	revision, err := r.ReadInt()
+	if err != nil {
+		return ErrCorruptLog
+	}
+	document, err := r.ReadBytes()
+	if err != nil {
+		return ErrCorruptLog
+	}
+	base, err := r.ReadInt()
+	if err != nil {
+		return ErrCorruptLog
+	}
+	baseDocument, err := r.ReadBytes()
+	if err != nil {
+		return ErrCorruptLog
+	}
+	attributions, err := r.ReadBytes()
+	if err != nil {
+		return ErrCorruptLog
+	}
+	if err := f.attributions.UnmarshalBinary(attributions); err != nil {
+		return ErrCorruptLog
+	}
+
+	if f.document, err = gollab.UnmarshalTokenArrayBinary(document, f.tokenType); err != nil {
+		return err
+	}
+	if f.history.baseDocument, err = gollab.UnmarshalTokenArrayBinary(baseDocument, f.tokenType); err != nil {
+		return err
+	}
+
+	f.history.base = base
+	f.logBase = base
+	f.snapshotRevision = revision
+	return nil
+}
+
+// marshalDocument encodes a document using encoding.BinaryMarshaler.
+func marshalDocument(document gollab.TokenArray) ([]byte, error) {
+	marshaler, ok := document.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, gollab.ErrNotBinaryMarshaler
+	}
+	return marshaler.MarshalBinary()
+}
+
+// writeSnapshot atomically replaces the snapshot with the current document with its Attributions and the base
+// document, and then compacts the log.
+func (f *FileStateStore) writeSnapshot() error {
+	document, err := marshalDocument(f.document)
+	if err != nil {
+		return err
+	}
+	baseDocument, err := marshalDocument(f.history.baseDocument)
+	if err != nil {
+		return err
+	}
+	attributions, err := f.attributions.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	var w binenc.Writer
+	w.WriteVarint(int64(f.history.revision()))
+	w.WriteBytes(document)
+	w.WriteVarint(int64(f.history.base))
+	w.WriteBytes(baseDocument)
+	w.WriteBytes(attributions)
+
+	path := filepath.Join(f.dir, fileStateStoreSnapshot)
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := writeRecord(file, w.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	if err := syncDir(f.dir); err != nil {
+		return err
+	}
+
+	f.snapshotRevision = f.history.revision()
+	if f.log != nil && f.history.base > f.logBase {
+		return f.compactLog()
+	}
+	return nil
+}
+
+// compactLog rewrites the log without the operations preceding the base revision.
+func (f *FileStateStore) compactLog() error {
+	data := make([]byte, f.logSize)
+	if _, err := f.log.ReadAt(data, 0); err != nil {
+		return err
+	}
+
+	r := bytes.NewReader(data)
+	keep := len(data)
+	for r.Len() > 0 {
+		offset := len(data) - r.Len()
+		payload, err := readRecord(r)
+		if err != nil {
+			return err
+		}
+		opMsg, _, err := decodeLogRecord(payload, f.tokenType)
+		if err != nil {
+			return err
+		}
+		if opMsg.Revision > f.history.base {
+			// Records are ordered by revision, so all of the remaining ones are kept.
+			keep = offset
+			break
+		}
+	}
+	data = data[keep:]
+
+	path := filepath.Join(f.dir, fileStateStoreLog)
+	file, err := os.OpenFile(path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err == nil {
+		err = syncDir(f.dir)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.log.Close()
+	f.log = file
+	f.logSize = int64(len(data))
+	f.logBase = f.history.base
+	return nil
+}
+
+// replayLog loads the operations logged after the base revision, applying those logged after the snapshot to the
+// document. The log is truncated after its last valid record.
+//
+// A record which is cut short, or which is corrupt or cannot be decoded but isn't followed by any valid record, is
+// considered torn by a crash while appending it, and truncated. ErrCorruptLog is returned if valid records follow it.
+func (f *FileStateStore) replayLog() error {
+	data, err := io.ReadAll(f.log)
+	if err != nil {
+		return err
+	}
+
+	r := bytes.NewReader(data)
+	var valid int64
+	for {
+		payload, err := readRecord(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		var opMsg OpMessage
+		var t time.Time
+		if err == nil {
+			opMsg, t, err = decodeLogRecord(payload, f.tokenType)
+		}
+		if err != nil {
+			if f.validRecordFollows(r) {
+				return ErrCorruptLog
+			}
+			break
+		}
+		valid = int64(len(data) - r.Len())
+
+		if opMsg.Revision <= f.history.base {
+			continue
+		}
+		if opMsg.Revision != f.history.revision()+1 {
+			return ErrCorruptLog
+		}
+		if opMsg.Revision > f.snapshotRevision {
+			if f.document, err = opMsg.Op.ApplyToTokenArray(f.document); err != nil {
+				return ErrCorruptLog
+			}
+			attribution := Attribution{AuthorID: opMsg.AuthorID, Revision: opMsg.Revision, Time: t}
+			if err := f.attributions.Apply(opMsg.Op, attribution); err != nil {
+				return ErrCorruptLog
+			}
+		}
+		f.history.append(opMsg, t)
+	}
+	if f.history.revision() < f.snapshotRevision {
+		return ErrCorruptLog
+	}
+
+	if valid < int64(len(data)) {
+		if err := f.log.Truncate(valid); err != nil {
+			return err
+		}
+		if err := f.log.Sync(); err != nil {
+			return err
+		}
+	}
+	if _, err := f.log.Seek(valid, io.SeekStart); err != nil {
+		return err
+	}
+	f.logSize = valid
+	return nil
+}
+
+// validRecordFollows reports whether a record which can be decoded can be read from r.
+func (f *FileStateStore) validRecordFollows(r *bytes.Reader) bool {
+	for {
+		payload, err := readRecord(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false
+		}
+		if err != nil {
+			continue
+		}
+		if _, _, err := decodeLogRecord(payload, f.tokenType); err == nil {
+			return true
+		}
+	}
+}
+
+// appendLog appends an operation applied at the given time to the log and syncs it to disk. If writing fails, the log
+// is truncated back to its previous size so that later records aren't hidden behind a torn one.
+func (f *FileStateStore) appendLog(opMsg OpMessage, t time.Time) error {
+	payload, err := encodeLogRecord(opMsg, t)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeRecord(&buf, payload); err != nil {
+		return err
+	}
+
+	if _, err := f.log.Write(buf.Bytes()); err == nil {
+		err = f.log.Sync()
+	}
+	if err != nil {
+		if rollbackErr := f.log.Truncate(f.logSize); rollbackErr != nil {
+			return fmt.Errorf("%v, truncating the log: %w", err, rollbackErr)
+		}
+		if _, rollbackErr := f.log.Seek(f.logSize, io.SeekStart); rollbackErr != nil {
+			return fmt.Errorf("%v, seeking the log: %w", err, rollbackErr)
+		}
+		return err
+	}
+
+	f.logSize += int64(buf.Len())
+	return nil
+}
+
+// SetRetentionPolicy sets the policy limiting the history kept by the store. It takes effect on the next ApplyClient,
+// while the dropped operations are removed from the log on the next snapshot.
+func (f *FileStateStore) SetRetentionPolicy(policy RetentionPolicy) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.retention = policy
+}
+
+// Current returns the current state consisting of the document and its revision number.
+func (f *FileStateStore) Current() (document gollab.TokenArray, revision int, err error) {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	return f.document, f.history.revision(), nil
+}
+
+// ApplyClient applies a client-side operation, persisting it to the log before broadcasting it. It returns
+// ErrRevisionTooOld if the operation is based on a revision which has been dropped according to the retention policy.
+func (f *FileStateStore) ApplyClient(opMsg OpMessage) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if _, err := f.history.compact(f.retention, time.Now()); err != nil {
+		return err
+	}
+
+	transformOps, err := f.history.since(opMsg.Revision)
+	if err != nil {
+		return err
+	}
+
+	res, err := ApplyClientOp(ApplyClientOpInput{
+		CurrentDocument: f.document,
+		CurrentRevision: f.history.revision(),
+		Op:              opMsg.Op,
+		TransformOps:    transformOps,
+	})
+	if err != nil {
+		return err
+	}
+
+	broadcast := OpMessage{
+		AuthorID: opMsg.AuthorID,
+		Op:       res.Op,
+		Revision: res.Revision,
+	}
+	now := time.Now()
+	attributions, err := f.attributions.applied(res.Op, Attribution{
+		AuthorID: opMsg.AuthorID,
+		Revision: res.Revision,
+		Time:     now,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Nothing may fail after the operation is logged, otherwise the state would lag behind the log.
+	if err := f.appendLog(broadcast, now); err != nil {
+		return err
+	}
+	*f.attributions = attributions
+	f.document = res.Document
+	f.history.append(broadcast, now)
+
+	// The operation is already durable, so a failed snapshot is retried on the next operation instead of failing it.
+	if f.snapshotInterval > 0 && f.history.revision()-f.snapshotRevision >= f.snapshotInterval {
+		f.writeSnapshot()
+	}
+
+	f.opStream <- broadcast
+	return nil
+}
+
+// DocumentAt returns the document at the given revision. It implements HistoryStore.
+func (f *FileStateStore) DocumentAt(revision int) (gollab.TokenArray, error) {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	return f.history.documentAt(revision)
+}
+
+// OpsRange returns the operations applied between the given revisions. It implements HistoryStore.
+func (f *FileStateStore) OpsRange(from, to int) ([]OpMessage, error) {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	return f.history.opsRange(from, to)
+}
+
+// Blame returns the attribution of the tokens between start and end. It implements BlameStore.
+func (f *FileStateStore) Blame(start, end int) ([]BlameSpan, error) {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	return f.attributions.Blame(start, end)
+}
+
+// Snapshot writes the current document to the snapshot, speeding up opening the store.
+func (f *FileStateStore) Snapshot() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return f.writeSnapshot()
+}
+
+// OperationStream is a channel returning operations to be broadcast to all clients.
+func (f *FileStateStore) OperationStream() <-chan OpMessage {
+	return f.opStream
+}
+
+// Close closes the log file.
+func (f *FileStateStore) Close() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return f.log.Close()
+}
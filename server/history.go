@@ -0,0 +1,103 @@
+package server
+
+import (
+	"time"
+
+	"github.com/danielslee/gollab"
+)
+
+// opHistory holds the operations applied to a document after the base revision, along with their authors and the
+// times they were applied, and a snapshot of the document at the base revision.
+type opHistory struct {
+	base         int
+	baseDocument gollab.TokenArray
+	ops          []gollab.CompositeOp
+	authors      []string
+	times        []time.Time
+}
+
+func (h *opHistory) revision() int {
+	return h.base + len(h.ops)
+}
+
+// since returns the operations applied after the given revision.
+func (h *opHistory) since(revision int) ([]gollab.CompositeOp, error) {
+	if err := h.checkRange(revision, h.revision()); err != nil {
+		return nil, err
+	}
+	return h.ops[revision-h.base:], nil
+}
+
+// checkRange checks that the history holds the revisions between from and to.
+func (h *opHistory) checkRange(from, to int) error {
+	if from < 0 || from > to || to > h.revision() {
+		return ErrUnknownRevision
+	}
+	if from < h.base {
+		return ErrRevisionTooOld
+	}
+	return nil
+}
+
+// documentAt reconstructs the document at the given revision by applying operations to the base document.
+func (h *opHistory) documentAt(revision int) (gollab.TokenArray, error) {
+	if err := h.checkRange(revision, revision); err != nil {
+		return nil, err
+	}
+
+	document := h.baseDocument
+	for _, op := range h.ops[:revision-h.base] {
+		var err error
+		if document, err = op.ApplyToTokenArray(document); err != nil {
+			return nil, err
+		}
+	}
+	return document, nil
+}
+
+// opsRange returns the operations transforming the document at revision from into the document at revision to.
+func (h *opHistory) opsRange(from, to int) ([]OpMessage, error) {
+	if err := h.checkRange(from, to); err != nil {
+		return nil, err
+	}
+
+	msgs := make([]OpMessage, 0, to-from)
+	for i := from - h.base; i < to-h.base; i++ {
+		msgs = append(msgs, OpMessage{
+			AuthorID: h.authors[i],
+			Op:       h.ops[i],
+			Revision: h.base + i + 1,
+		})
+	}
+	return msgs, nil
+}
+
+func (h *opHistory) append(opMsg OpMessage, t time.Time) {
+	h.ops = append(h.ops, opMsg.Op)
+	h.authors = append(h.authors, opMsg.AuthorID)
+	h.times = append(h.times, t)
+}
+
+// compact drops the operations falling outside of the retention policy, moving the base revision forward by applying
+// them to the base document. It returns the number of dropped operations.
+func (h *opHistory) compact(p RetentionPolicy, now time.Time) (int, error) {
+	n := p.expired(h.times, now)
+	if n == 0 {
+		return 0, nil
+	}
+
+	document := h.baseDocument
+	for _, op := range h.ops[:n] {
+		var err error
+		if document, err = op.ApplyToTokenArray(document); err != nil {
+			return 0, err
+		}
+	}
+
+	h.base += n
+	h.baseDocument = document
+	h.ops = h.ops[n:]
+	h.authors = h.authors[n:]
+	h.times = h.times[n:]
+	return n, nil
+}
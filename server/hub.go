@@ -0,0 +1,311 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrHubClosed is an error indicating that the Hub has been closed.
+var ErrHubClosed = errors.New("hub closed")
+
+// ErrDocumentNotLoaded is an error indicating that a document isn't loaded by the Hub.
+var ErrDocumentNotLoaded = errors.New("document not loaded")
+
+// ErrUnknownClient is an error indicating that a client hasn't joined the document.
+var ErrUnknownClient = errors.New("unknown client")
+
+// StateStoreFactory creates the StateStore of the document with the given ID. It is used by a Hub to load documents.
+type StateStoreFactory func(docID string) (StateStore, error)
+
+// Hub serves any number of documents identified by their IDs, running a DocumentServer for each of them.
+//
+// A document is loaded using the StateStoreFactory when its first client joins, and unloaded once it has had no clients
+// for the idle timeout. When a document is unloaded, its DocumentServer is stopped, closing the channels of any clients
+// left, and its StateStore is closed if it implements io.Closer.
+//
+// So that a client not reading its messages can't stall a document, the Hub's DocumentServers drop such clients, as
+// described by DocumentServer.SetDropSlowClients. Clients whose channel gets closed by the DocumentServer, such as after
+// sending an invalid operation or not reading their messages, still have to leave the document.
+type Hub struct {
+	factory     StateStoreFactory
+	idleTimeout time.Duration
+
+	mux       sync.Mutex
+	documents map[string]*hubDocument
+	// stopping holds channels closed once the documents being unloaded have been stopped, so they aren't loaded again
+	// before their StateStore is closed.
+	stopping map[string]chan struct{}
+	closed   bool
+}
+
+type hubDocument struct {
+	// ready is closed once the document has been loaded, setting either server and store or err.
+	ready  chan struct{}
+	server *DocumentServer
+	store  StateStore
+	err    error
+
+	// clients, joining and idle are guarded by Hub.mux
+	clients map[int]struct{}
+	joining int
+	idle    int
+
+	// stopping is closed when the document starts being stopped, releasing any Send waiting for the DocumentServer.
+	stopping chan struct{}
+	stopOnce sync.Once
+	// mux guards closed, making sure no messages are sent to the DocumentServer after it has been stopped.
+	mux    sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewHub creates a new Hub loading documents using the factory and unloading them after idleTimeout without clients.
+func NewHub(factory StateStoreFactory, idleTimeout time.Duration) *Hub {
+	return &Hub{
+		factory:     factory,
+		idleTimeout: idleTimeout,
+		documents:   make(map[string]*hubDocument),
+		stopping:    make(map[string]chan struct{}),
+	}
+}
+
+// load loads the document, starting its DocumentServer. It is called by the first client joining the document, waiting
+// for the previous instance of the document, if any, to be stopped first.
+func (h *Hub) load(docID string, d *hubDocument, previous <-chan struct{}) {
+	defer close(d.ready)
+
+	if previous != nil {
+		<-previous
+	}
+
+	if d.store, d.err = h.factory(docID); d.err != nil {
+		h.mux.Lock()
+		if h.documents[docID] == d {
+			delete(h.documents, docID)
+		}
+		h.mux.Unlock()
+		return
+	}
+
+	d.server = NewDocumentServer(d.store)
+	d.server.SetDropSlowClients(true)
+	go func() {
+		d.server.Run()
+		close(d.done)
+	}()
+}
+
+// stop stops the DocumentServer of the document and closes its StateStore.
+func (d *hubDocument) stop() error {
+	<-d.ready
+	if d.err != nil {
+		return nil
+	}
+
+	d.stopOnce.Do(func() {
+		close(d.stopping)
+	})
+
+	d.mux.Lock()
+	if d.closed {
+		d.mux.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.server.receiveChan)
+	d.mux.Unlock()
+
+	<-d.done
+	if closer, ok := d.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// scheduleUnload unloads the document after the idle timeout if it has no clients. It has to be called with h.mux held.
+func (h *Hub) scheduleUnload(docID string, d *hubDocument) {
+	if len(d.clients) > 0 || d.joining > 0 {
+		return
+	}
+
+	d.idle++
+	idle := d.idle
+	time.AfterFunc(h.idleTimeout, func() {
+		h.mux.Lock()
+		if h.documents[docID] != d || d.idle != idle || len(d.clients) > 0 || d.joining > 0 {
+			h.mux.Unlock()
+			return
+		}
+		delete(h.documents, docID)
+		stopped := make(chan struct{})
+		h.stopping[docID] = stopped
+		h.mux.Unlock()
+
+		if err := d.stop(); err != nil {
+			log.Println("err closing state store:", err)
+		}
+
+		h.mux.Lock()
+		if h.stopping[docID] == stopped {
+			delete(h.stopping, docID)
+		}
+		h.mux.Unlock()
+		close(stopped)
+	})
+}
+
+// Join attaches a new client to the document with the given ID, loading the document if needed. It returns the client's
+// id number and a channel on which the client can receive messages from the server, as DocumentServer.NewClient does.
+func (h *Hub) Join(docID string) (clientID int, sendToClientChan <-chan interface{}, err error) {
+	h.mux.Lock()
+	if h.closed {
+		h.mux.Unlock()
+		return 0, nil, ErrHubClosed
+	}
+	d, loaded := h.documents[docID]
+	previous := h.stopping[docID]
+	if !loaded {
+		d = &hubDocument{
+			ready:    make(chan struct{}),
+			clients:  make(map[int]struct{}),
+			stopping: make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+		h.documents[docID] = d
+	}
+	d.joining++
+	d.idle++
+	h.mux.Unlock()
+
+	if !loaded {
+		h.load(docID, d, previous)
+	}
+	<-d.ready
+	if d.err != nil {
+		err = d.err
+	} else {
+		clientID, sendToClientChan, err = d.newClient()
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	d.joining--
+	if err == nil {
+		d.clients[clientID] = struct{}{}
+	}
+	if h.documents[docID] == d {
+		h.scheduleUnload(docID, d)
+	}
+	return
+}
+
+// newClient attaches a new client to the DocumentServer unless it has been stopped.
+func (d *hubDocument) newClient() (clientID int, sendToClientChan <-chan interface{}, err error) {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+
+	if d.closed {
+		return 0, nil, ErrHubClosed
+	}
+	clientID, sendToClientChan = d.server.NewClient()
+	return clientID, sendToClientChan, nil
+}
+
+// Leave detaches a client from the document with the given ID, clearing its presence.
+func (h *Hub) Leave(docID string, clientID int) error {
+	h.mux.Lock()
+	d, ok := h.documents[docID]
+	if !ok {
+		h.mux.Unlock()
+		return ErrDocumentNotLoaded
+	}
+	if _, ok := d.clients[clientID]; !ok {
+		h.mux.Unlock()
+		return ErrUnknownClient
+	}
+	delete(d.clients, clientID)
+	h.scheduleUnload(docID, d)
+	h.mux.Unlock()
+
+	d.server.RemoveClient(clientID)
+	return nil
+}
+
+// Send routes a message from a client to the DocumentServer of the document with the given ID. It blocks while the
+// DocumentServer is busy, unless the document gets unloaded, in which case ErrDocumentNotLoaded is returned.
+func (h *Hub) Send(docID string, msg ClientMessage) error {
+	h.mux.Lock()
+	d, ok := h.documents[docID]
+	closed := h.closed
+	h.mux.Unlock()
+	if closed {
+		return ErrHubClosed
+	}
+	if !ok {
+		return ErrDocumentNotLoaded
+	}
+
+	<-d.ready
+	if d.err != nil {
+		return ErrDocumentNotLoaded
+	}
+
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+	if d.closed {
+		return ErrDocumentNotLoaded
+	}
+	select {
+	case d.server.receiveChan <- msg:
+		return nil
+	case <-d.stopping:
+		return ErrDocumentNotLoaded
+	}
+}
+
+// Documents returns the sorted IDs of the loaded documents.
+func (h *Hub) Documents() []string {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	ids := make([]string, 0, len(h.documents))
+	for id := range h.documents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Close unloads all documents, waiting for their DocumentServers to stop and closing their StateStores. It returns the
+// first error encountered while closing a StateStore.
+func (h *Hub) Close() error {
+	h.mux.Lock()
+	if h.closed {
+		h.mux.Unlock()
+		return ErrHubClosed
+	}
+	h.closed = true
+	documents := h.documents
+	h.documents = make(map[string]*hubDocument)
+	stopping := make([]chan struct{}, 0, len(h.stopping))
+	for _, c := range h.stopping {
+		stopping = append(stopping, c)
+	}
+	h.mux.Unlock()
+
+	var firstErr error
+	for _, d := range documents {
+		if err := d.stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, c := range stopping {
+		<-c
+	}
+	return firstErr
+}
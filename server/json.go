@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/danielslee/gollab"
+)
+
+// UnmarshalInitMessage decodes an InitMessage from JSON, decoding its document using the given TokenArrayType, which
+// has to implement gollab.TokenArrayUnmarshaler.
+func UnmarshalInitMessage(data []byte, t gollab.TokenArrayType) (InitMessage, error) {
+	var raw struct {
+		Document json.RawMessage   `json:"document"`
+		Revision int               `json:"revision"`
+		Presence []PresenceMessage `json:"presence"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return InitMessage{}, err
+	}
+
+	document, err := gollab.UnmarshalTokenArray(raw.Document, t)
+	if err != nil {
+		return InitMessage{}, err
+	}
+
+	return InitMessage{
+		Document: document,
+		Revision: raw.Revision,
+		Presence: raw.Presence,
+	}, nil
+}
+
+// UnmarshalOpMessage decodes an OpMessage from JSON, decoding the tokens of its operation using the given
+// TokenArrayType, which has to implement gollab.TokenArrayUnmarshaler.
+func UnmarshalOpMessage(data []byte, t gollab.TokenArrayType) (OpMessage, error) {
+	var raw struct {
+		AuthorID string          `json:"authorID"`
+		Op       json.RawMessage `json:"op"`
+		Revision int             `json:"revision"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return OpMessage{}, err
+	}
+
+	op, err := gollab.UnmarshalCompositeOp(raw.Op, t)
+	if err != nil {
+		return OpMessage{}, err
+	}
+
+	return OpMessage{
+		AuthorID: raw.AuthorID,
+		Op:       op,
+		Revision: raw.Revision,
+	}, nil
+}
@@ -0,0 +1,27 @@
+package server
+
+import "time"
+
+// RetentionPolicy limits the history kept by a StateStore for transforming client operations based on past revisions.
+// Operations falling outside of the policy are dropped, and clients sending operations based on revisions preceding
+// them receive ErrRevisionTooOld, telling them to resync. The zero value keeps the whole history.
+type RetentionPolicy struct {
+	// MaxRevisions is the maximum number of most recent revisions kept. Zero means no limit.
+	MaxRevisions int
+	// MaxAge is the maximum time an operation is kept after being applied. Zero means no limit.
+	MaxAge time.Duration
+}
+
+// expired returns the number of operations, applied at the given times, which fall outside of the policy.
+func (p RetentionPolicy) expired(times []time.Time, now time.Time) int {
+	var n int
+	if p.MaxRevisions > 0 && len(times) > p.MaxRevisions {
+		n = len(times) - p.MaxRevisions
+	}
+	if p.MaxAge > 0 {
+		for n < len(times) && now.Sub(times[n]) > p.MaxAge {
+			n++
+		}
+	}
+	return n
+}
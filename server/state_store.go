@@ -2,6 +2,7 @@ package server
 
 import (
 	"sync"
+	"time"
 
 	"github.com/danielslee/gollab"
 )
@@ -14,65 +15,128 @@ type StateStore interface {
 	OperationStream() <-chan OpMessage
 }
 
-// MemoryStateStore implements a basic StateStore.
+// HistoryStore is a StateStore giving access to past revisions of the document, such as for browsing its version
+// history. Both methods return ErrRevisionTooOld for revisions dropped according to the store's RetentionPolicy.
+//
+// A past version can be restored by applying an operation turning the current document into the one returned by
+// DocumentAt, computed using gollab.Diff.
+type HistoryStore interface {
+	StateStore
+	// DocumentAt returns the document at the given revision.
+	DocumentAt(revision int) (gollab.TokenArray, error)
+	// OpsRange returns the operations transforming the document at revision from into the document at revision to,
+	// each with its author and the revision it resulted in.
+	OpsRange(from, to int) ([]OpMessage, error)
+}
+
+// MemoryStateStore implements a basic StateStore. By default, it keeps all operations in memory, so SetRetentionPolicy
+// should be used for long-lived documents.
 type MemoryStateStore struct {
 	mux sync.RWMutex
 
-	document gollab.TokenArray
-	ops      []gollab.CompositeOp
-	opStream chan OpMessage
+	document     gollab.TokenArray
+	history      opHistory
+	retention    RetentionPolicy
+	attributions *Attributions
+	opStream     chan OpMessage
 }
 
 // NewMemoryStateStore Creates a new NewMemoryStateStore.
 func NewMemoryStateStore(document gollab.TokenArray) *MemoryStateStore {
 	return &MemoryStateStore{
-		opStream: make(chan OpMessage, 128),
-		document: document,
+		opStream:     make(chan OpMessage, 128),
+		document:     document,
+		history:      opHistory{baseDocument: document},
+		attributions: NewAttributions(document.Len()),
 	}
 }
 
+// SetRetentionPolicy sets the policy limiting the history kept by the store. It takes effect on the next ApplyClient.
+func (m *MemoryStateStore) SetRetentionPolicy(policy RetentionPolicy) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.retention = policy
+}
+
 // Current returns the current state consisting of the document and its revision number.
 func (m *MemoryStateStore) Current() (document gollab.TokenArray, revision int, err error) {
 	m.mux.RLock()
 	defer m.mux.RUnlock()
 
 	document = m.document
-	revision = len(m.ops)
+	revision = m.history.revision()
 	return
 }
 
-// ApplyClient applies a client-side operation.
+// ApplyClient applies a client-side operation. It returns ErrRevisionTooOld if the operation is based on a revision
+// which has been dropped according to the retention policy.
 func (m *MemoryStateStore) ApplyClient(opMsg OpMessage) error {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
-	if opMsg.Revision < 0 || (opMsg.Revision > len(m.ops)) {
-		return ErrUnknownRevision
+	if _, err := m.history.compact(m.retention, time.Now()); err != nil {
+		return err
+	}
+
+	transformOps, err := m.history.since(opMsg.Revision)
+	if err != nil {
+		return err
 	}
 
 	res, err := ApplyClientOp(ApplyClientOpInput{
 		CurrentDocument: m.document,
-		CurrentRevision: len(m.ops),
+		CurrentRevision: m.history.revision(),
 		Op:              opMsg.Op,
-		TransformOps:    m.ops[opMsg.Revision:],
+		TransformOps:    transformOps,
 	})
 
 	if err != nil {
 		return err
 	}
 
-	m.document = res.Document
-	m.ops = append(m.ops, res.Op)
-
-	m.opStream <- OpMessage{
+	broadcast := OpMessage{
 		AuthorID: opMsg.AuthorID,
 		Op:       res.Op,
 		Revision: res.Revision,
 	}
+	now := time.Now()
+	attribution := Attribution{AuthorID: opMsg.AuthorID, Revision: res.Revision, Time: now}
+	if err := m.attributions.Apply(res.Op, attribution); err != nil {
+		return err
+	}
+	m.document = res.Document
+	m.history.append(broadcast, now)
+
+	m.opStream <- broadcast
 
 	return nil
 }
 
+// DocumentAt returns the document at the given revision. It implements HistoryStore.
+func (m *MemoryStateStore) DocumentAt(revision int) (gollab.TokenArray, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.history.documentAt(revision)
+}
+
+// OpsRange returns the operations applied between the given revisions. It implements HistoryStore.
+func (m *MemoryStateStore) OpsRange(from, to int) ([]OpMessage, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.history.opsRange(from, to)
+}
+
+// Blame returns the attribution of the tokens between start and end. It implements BlameStore.
+func (m *MemoryStateStore) Blame(start, end int) ([]BlameSpan, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.attributions.Blame(start, end)
+}
+
 // OperationStream is a channel returning operations to be broadcast to all clients.
 func (m *MemoryStateStore) OperationStream() <-chan OpMessage {
 	return m.opStream
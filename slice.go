@@ -1,6 +1,7 @@
 package gollab
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -58,9 +59,52 @@ func newStack(ops []PrimitiveOp) *stack {
 	}
 }
 
+// slicedPair is a pair of PrimitiveOps of equal length produced by trySlice, along with the indexes of the operations
+// they were sliced from.
+type slicedPair struct {
+	a, b       PrimitiveOp
+	aIdx, bIdx int
+}
+
+// wrapError wraps an error returned by a PrimitiveOp into a LengthMismatchError or an UnexpectedOpError.
+func (p slicedPair) wrapError(err error, aLengthFunc, bLengthFunc lengthFunc) error {
+	if err == ErrLengthMismatch {
+		return &LengthMismatchError{
+			AIndex:  p.aIdx,
+			BIndex:  p.bIdx,
+			ALength: aLengthFunc(p.a),
+			BLength: bLengthFunc(p.b),
+		}
+	}
+	return &UnexpectedOpError{
+		AIndex: p.aIdx,
+		BIndex: p.bIdx,
+		A:      p.a,
+		B:      p.b,
+	}
+}
+
 func slice(a, b CompositeOp, aLengthFunc, bLengthFunc lengthFunc) (aSliced, bSliced []PrimitiveOp) {
-	if aLengthFunc(a) != bLengthFunc(b) {
-		panic(ErrLengthMismatch)
+	pairs, err := trySlice(a, b, aLengthFunc, bLengthFunc)
+	if err != nil {
+		panic(errors.Unwrap(err))
+	}
+
+	for _, p := range pairs {
+		aSliced = append(aSliced, p.a)
+		bSliced = append(bSliced, p.b)
+	}
+	return
+}
+
+func trySlice(a, b CompositeOp, aLengthFunc, bLengthFunc lengthFunc) (pairs []slicedPair, err error) {
+	if aLength, bLength := aLengthFunc(a), bLengthFunc(b); aLength != bLength {
+		return nil, &LengthMismatchError{
+			AIndex:  -1,
+			BIndex:  -1,
+			ALength: aLength,
+			BLength: bLength,
+		}
 	}
 
 	aStack := newStack(a)
@@ -74,46 +118,47 @@ func slice(a, b CompositeOp, aLengthFunc, bLengthFunc lengthFunc) (aSliced, bSli
 		aLength := aLengthFunc(aStack.current)
 		bLength := bLengthFunc(bStack.current)
 
+		if aLength < 0 || bLength < 0 {
+			return nil, &LengthMismatchError{
+				AIndex:  aStack.idx,
+				BIndex:  bStack.idx,
+				ALength: aLength,
+				BLength: bLength,
+			}
+		}
+
 		if aLength == 0 && !aStack.isEOF() {
-			aSliced = append(aSliced, aStack.current)
-			bSliced = append(bSliced, NoOp{})
+			pairs = append(pairs, slicedPair{a: aStack.current, b: NoOp{}, aIdx: aStack.idx, bIdx: bStack.idx})
 			aStack.next()
 			continue
 		} else if bLength == 0 && !bStack.isEOF() {
-			aSliced = append(aSliced, NoOp{})
-			bSliced = append(bSliced, bStack.current)
+			pairs = append(pairs, slicedPair{a: NoOp{}, b: bStack.current, aIdx: aStack.idx, bIdx: bStack.idx})
 			bStack.next()
 			continue
 		}
 
 		if aStack.isEOF() || bStack.isEOF() {
-			panic(ErrLengthMismatch)
+			return nil, &LengthMismatchError{
+				AIndex:  aStack.idx,
+				BIndex:  bStack.idx,
+				ALength: aLength,
+				BLength: bLength,
+			}
 		}
 
+		pair := slicedPair{a: aStack.current, b: bStack.current, aIdx: aStack.idx, bIdx: bStack.idx}
 		if aLength == bLength {
-			aSliced = append(aSliced, aStack.current)
-			bSliced = append(bSliced, bStack.current)
-
 			aStack.next()
 			bStack.next()
 		} else if aLength > bLength {
-			a1 := aStack.current.Slice(0, bLength)
-			a2 := aStack.current.Slice(bLength, aLength)
-
-			aSliced = append(aSliced, a1)
-			bSliced = append(bSliced, bStack.current)
-
-			aStack.current = a2
+			pair.a = aStack.current.Slice(0, bLength)
+			aStack.current = aStack.current.Slice(bLength, aLength)
 			bStack.next()
 		} else {
-			b1 := bStack.current.Slice(0, aLength)
-			b2 := bStack.current.Slice(aLength, bLength)
-
-			aSliced = append(aSliced, aStack.current)
-			bSliced = append(bSliced, b1)
-
+			pair.b = bStack.current.Slice(0, aLength)
 			aStack.next()
-			bStack.current = b2
+			bStack.current = bStack.current.Slice(aLength, bLength)
 		}
+		pairs = append(pairs, pair)
 	}
 }
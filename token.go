@@ -32,6 +32,14 @@ type TokenArrayBuilder interface {
 	TokenArray() TokenArray
 }
 
+// TokenArrayEditor is implemented by a TokenArray able to apply an operation to itself more efficiently than by
+// reading and writing every token, for example by copying the retained spans in bulk. See
+// CompositeOp.ApplyToTokenArray.
+type TokenArrayEditor interface {
+	TokenArray
+	ApplyOp(op CompositeOp) (TokenArray, error)
+}
+
 // TokenArrayReader is a struct implementing a TokenReader given a TokenArray.
 type TokenArrayReader struct {
 	tokenArray TokenArray
@@ -14,8 +14,10 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
-func testTransform(t *testing.T, input string, op1, op2 gollab.CompositeOp) {
-	op1Prime, op2Prime := op1.Transform(op2)
+type transformFunc func(a, b gollab.CompositeOp) (aPrime, bPrime gollab.CompositeOp)
+
+func testTransform(t *testing.T, input string, op1, op2 gollab.CompositeOp, transform transformFunc) {
+	op1Prime, op2Prime := transform(op1, op2)
 
 	afterOp1, err := runetoken.ApplyToString(op1, input)
 	if err != nil {
@@ -55,7 +57,50 @@ func TestTransform(t *testing.T) {
 			str := randString(l)
 			op1 := randomCompositeOp(l, l+rand.Intn(10)-5)
 			op2 := randomCompositeOp(l, l+rand.Intn(10)-5)
-			testTransform(t, str, op1, op2)
+			testTransform(t, str, op1, op2, gollab.CompositeOp.Transform)
+		})
+	}
+}
+
+func TestTransformWithPriority(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			str := randString(l)
+			op1 := randomCompositeOp(l, l+rand.Intn(10)-5)
+			op2 := randomCompositeOp(l, l+rand.Intn(10)-5)
+			aWins := rand.Intn(2) == 0
+			testTransform(t, str, op1, op2, func(a, b gollab.CompositeOp) (aPrime, bPrime gollab.CompositeOp) {
+				return a.TransformWithPriority(b, aWins)
+			})
 		})
 	}
 }
+
+func TestTransformWithPriorityTie(t *testing.T) {
+	a := gollab.NewCompositeOp(gollab.Retain{Count: 2}, gollab.Insert{Tokens: runetoken.Array("a")},
+		gollab.Retain{Count: 3})
+	b := gollab.NewCompositeOp(gollab.Retain{Count: 2}, gollab.Insert{Tokens: runetoken.Array("b")},
+		gollab.Retain{Count: 3})
+
+	for _, test := range []struct {
+		aWins    bool
+		expected string
+	}{
+		{true, "heabllo"},
+		{false, "heballo"},
+	} {
+		aPrime, bPrime := a.TransformWithPriority(b, test.aWins)
+
+		afterA, _ := runetoken.ApplyToString(a, "hello")
+		afterABPrime, _ := runetoken.ApplyToString(bPrime, afterA)
+		afterB, _ := runetoken.ApplyToString(b, "hello")
+		afterBAPrime, _ := runetoken.ApplyToString(aPrime, afterB)
+
+		if afterABPrime != test.expected || afterBAPrime != test.expected {
+			t.Errorf("aWins: %v, expected '%s', got '%s' and '%s'", test.aWins, test.expected,
+				afterABPrime, afterBAPrime)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package gollab_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/runetoken"
+	"github.com/danielslee/gollab/server"
+)
+
+func TestTryTransformLengthMismatch(t *testing.T) {
+	a := gollab.NewCompositeOp(gollab.Retain{Count: 3})
+	b := gollab.NewCompositeOp(gollab.Retain{Count: 4})
+
+	_, _, err := a.TryTransform(b)
+	var lengthErr *gollab.LengthMismatchError
+	if !errors.As(err, &lengthErr) || !errors.Is(err, gollab.ErrLengthMismatch) {
+		t.Fatalf("expected a LengthMismatchError, got %v", err)
+	}
+	if lengthErr.AIndex != -1 || lengthErr.BIndex != -1 || lengthErr.ALength != 3 || lengthErr.BLength != 4 {
+		t.Errorf("unexpected error: %+v", lengthErr)
+	}
+}
+
+func TestTryTransformNegativeCount(t *testing.T) {
+	a := gollab.CompositeOp{gollab.Retain{Count: -2}, gollab.Retain{Count: 5}}
+	b := gollab.NewCompositeOp(gollab.Retain{Count: 1}, gollab.Delete{Count: 2})
+
+	_, _, err := a.TryTransform(b)
+	var lengthErr *gollab.LengthMismatchError
+	if !errors.As(err, &lengthErr) {
+		t.Fatalf("expected a LengthMismatchError, got %v", err)
+	}
+	if lengthErr.AIndex != 0 || lengthErr.ALength != -2 {
+		t.Errorf("unexpected error: %+v", lengthErr)
+	}
+}
+
+func TestTryTransformUnexpectedOp(t *testing.T) {
+	a := gollab.CompositeOp{gollab.Retain{Count: 1}, gollab.NoOp{}}
+	b := gollab.NewCompositeOp(gollab.Retain{Count: 1})
+
+	_, _, err := a.TryTransform(b)
+	var unexpectedErr *gollab.UnexpectedOpError
+	if !errors.As(err, &unexpectedErr) || !errors.Is(err, gollab.ErrUnexpectedOp) {
+		t.Fatalf("expected an UnexpectedOpError, got %v", err)
+	}
+	if unexpectedErr.AIndex != 1 {
+		t.Errorf("unexpected error: %+v", unexpectedErr)
+	}
+}
+
+func TestTryComposeLengthMismatch(t *testing.T) {
+	a := gollab.NewCompositeOp(gollab.Retain{Count: 2}, gollab.Insert{Tokens: runetoken.Array("a")})
+	b := gollab.NewCompositeOp(gollab.Retain{Count: 2})
+
+	if _, err := a.TryCompose(b); !errors.Is(err, gollab.ErrLengthMismatch) {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
+
+func TestTransformPanicsWithSentinel(t *testing.T) {
+	defer func() {
+		if r := recover(); r != gollab.ErrLengthMismatch {
+			t.Errorf("expected a panic with ErrLengthMismatch, got %v", r)
+		}
+	}()
+
+	gollab.NewCompositeOp(gollab.Retain{Count: 3}).Transform(gollab.NewCompositeOp(gollab.Retain{Count: 4}))
+}
+
+// rejectingOp is a markOp which cannot be transformed or composed with anything.
+type rejectingOp struct {
+	markOp
+}
+
+func (r rejectingOp) Compose(b gollab.PrimitiveOp) gollab.PrimitiveOp {
+	panic(gollab.ErrUnexpectedOp)
+}
+
+func (r rejectingOp) Transform(b gollab.PrimitiveOp) (aPrime, bPrime gollab.PrimitiveOp) {
+	panic(gollab.ErrUnexpectedOp)
+}
+
+func TestTransformUnknownOp(t *testing.T) {
+	a := gollab.CompositeOp{markOp{count: 3}}
+	b := gollab.NewCompositeOp(gollab.Retain{Count: 3})
+
+	aPrime, bPrime, err := a.TryTransform(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(aPrime, a) || !reflect.DeepEqual(bPrime, b) {
+		t.Errorf("expected the operations to be transformed using markOp.Transform, got %v and %v", aPrime, bPrime)
+	}
+	if composed, err := a.TryCompose(b); err != nil || !reflect.DeepEqual(composed, a) {
+		t.Errorf("expected the operations to be composed using markOp.Compose, got %v (%v)", composed, err)
+	}
+
+	a = gollab.CompositeOp{rejectingOp{markOp{count: 3}}}
+	var opErr *gollab.UnexpectedOpError
+	if _, _, err := a.TryTransform(b); !errors.As(err, &opErr) {
+		t.Errorf("expected an UnexpectedOpError, got %v", err)
+	}
+	if _, err := a.TryCompose(b); !errors.As(err, &opErr) {
+		t.Errorf("expected an UnexpectedOpError, got %v", err)
+	}
+}
+
+func TestApplyClientOpInvalid(t *testing.T) {
+	for _, op := range []gollab.CompositeOp{
+		gollab.NewCompositeOp(gollab.Retain{Count: 4}),
+		gollab.NewCompositeOp(gollab.Retain{Count: 6}),
+		{gollab.Retain{Count: 7}, gollab.Delete{Count: -2}},
+	} {
+		_, err := server.ApplyClientOp(server.ApplyClientOpInput{
+			CurrentDocument: runetoken.Array("hello!"),
+			CurrentRevision: 1,
+			Op:              op,
+			TransformOps: []gollab.CompositeOp{
+				gollab.NewCompositeOp(gollab.Retain{Count: 5}, gollab.Insert{Tokens: runetoken.Array("!")}),
+			},
+		})
+		if !errors.Is(err, server.ErrInvalidOperation) {
+			t.Errorf("%v: expected ErrInvalidOperation, got %v", op, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package gollab_test
+
+import (
+	"fmt"
+	"github.com/danielslee/gollab/runetoken"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/client"
+)
+
+func applyString(t *testing.T, op gollab.CompositeOp, input string) string {
+	output, err := runetoken.ApplyToString(op, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+func TestUndoManagerUndoRedo(t *testing.T) {
+	undo := client.NewUndoManager(0)
+
+	doc := "hello"
+	ops := []gollab.CompositeOp{
+		gollab.NewCompositeOp(gollab.Delete{Count: 1}, gollab.Insert{Tokens: runetoken.Array("H")},
+			gollab.Retain{Count: 4}),
+		gollab.NewCompositeOp(gollab.Retain{Count: 5}, gollab.Insert{Tokens: runetoken.Array(", World")}),
+	}
+	for _, op := range ops {
+		if err := undo.Record(op, runetoken.Array(doc)); err != nil {
+			t.Fatal(err)
+		}
+		doc = applyString(t, op, doc)
+	}
+
+	expected := []string{"Hello", "hello"}
+	for _, e := range expected {
+		op, err := undo.Undo(runetoken.Array(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc = applyString(t, op, doc); doc != e {
+			t.Errorf("expected '%s' after undo, got '%s'", e, doc)
+		}
+	}
+
+	if _, err := undo.Undo(runetoken.Array(doc)); err != client.ErrNothingToUndo {
+		t.Errorf("expected ErrNothingToUndo, got %v", err)
+	}
+
+	expected = []string{"Hello", "Hello, World"}
+	for _, e := range expected {
+		op, err := undo.Redo(runetoken.Array(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc = applyString(t, op, doc); doc != e {
+			t.Errorf("expected '%s' after redo, got '%s'", e, doc)
+		}
+	}
+
+	if _, err := undo.Redo(runetoken.Array(doc)); err != client.ErrNothingToRedo {
+		t.Errorf("expected ErrNothingToRedo, got %v", err)
+	}
+}
+
+func TestUndoManagerGrouping(t *testing.T) {
+	undo := client.NewUndoManager(time.Hour)
+
+	doc := ""
+	for i, r := range "Hello" {
+		op := gollab.NewCompositeOp(gollab.Insert{Tokens: runetoken.Array{r}})
+		if i > 0 {
+			op = gollab.NewCompositeOp(gollab.Retain{Count: i}, gollab.Insert{Tokens: runetoken.Array{r}})
+		}
+		if err := undo.Record(op, runetoken.Array(doc)); err != nil {
+			t.Fatal(err)
+		}
+		doc = applyString(t, op, doc)
+	}
+
+	undo.BreakGroup()
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 5}, gollab.Insert{Tokens: runetoken.Array("!")})
+	if err := undo.Record(op, runetoken.Array(doc)); err != nil {
+		t.Fatal(err)
+	}
+	doc = applyString(t, op, doc)
+
+	expected := []string{"Hello", ""}
+	for _, e := range expected {
+		op, err := undo.Undo(runetoken.Array(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc = applyString(t, op, doc); doc != e {
+			t.Errorf("expected '%s' after undo, got '%s'", e, doc)
+		}
+	}
+
+	if undo.CanUndo() {
+		t.Error("expected nothing left to undo")
+	}
+}
+
+// testUndoConcurrent records a local operation editing the end of a document and applies a remote operation editing
+// its start. Undoing the local operation should leave only the remote one applied and redoing it should restore both.
+func testUndoConcurrent(t *testing.T, prefixLength, suffixLength int) {
+	input := randString(prefixLength + suffixLength + 1)
+	local := gollab.NewCompositeOp(append([]gollab.PrimitiveOp{gollab.Retain{Count: prefixLength + 1}},
+		randomPrimitiveOps(suffixLength, suffixLength+rand.Intn(6)-3)...)...)
+	remote := gollab.NewCompositeOp(append(randomPrimitiveOps(prefixLength, prefixLength+rand.Intn(6)-3),
+		gollab.Retain{Count: suffixLength + 1})...)
+
+	undo := client.NewUndoManager(0)
+	if err := undo.Record(local, runetoken.Array(input)); err != nil {
+		t.Fatal(err)
+	}
+	doc := applyString(t, local, input)
+
+	var state client.State
+	state, _ = state.ApplyClientOp(local)
+	state, documentOp := state.ApplyServerOp(remote)
+	undo.Transform(documentOp)
+	doc = applyString(t, documentOp, doc)
+	withBoth := doc
+
+	undoOp, err := undo.Undo(runetoken.Array(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc = applyString(t, undoOp, doc)
+	if expected := applyString(t, remote, input); doc != expected {
+		t.Errorf("expected '%s' after undo, got '%s'", expected, doc)
+	}
+
+	redoOp, err := undo.Redo(runetoken.Array(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc = applyString(t, redoOp, doc); doc != withBoth {
+		t.Errorf("expected '%s' after redo, got '%s'", withBoth, doc)
+	}
+}
+
+func TestUndoManagerConcurrent(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			testUndoConcurrent(t, rand.Intn(10)+3, rand.Intn(10)+3)
+		})
+	}
+}
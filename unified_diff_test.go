@@ -0,0 +1,112 @@
+package gollab_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/danielslee/gollab/runetoken"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func randLines(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = randString(rand.Intn(4))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestUnifiedDiffRoundTrip(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			old := randLines(rand.Intn(30))
+			if rand.Intn(2) == 0 {
+				old += "\n"
+			}
+			new := randLines(rand.Intn(30))
+
+			diff, err := runetoken.FormatUnifiedDiff(runetoken.Diff(old, new), runetoken.Array(old), "a", "b",
+				rand.Intn(4))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			op, err := runetoken.ParseUnifiedDiff(diff, runetoken.Array(old))
+			if err != nil {
+				t.Fatalf("%v, diff:\n%s", err, diff)
+			}
+
+			applied, err := runetoken.ApplyToString(op, old)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if applied != new {
+				t.Errorf("applied(%q) != new(%q), diff:\n%s", applied, new, diff)
+			}
+		})
+	}
+}
+
+func TestFormatUnifiedDiff(t *testing.T) {
+	old := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten"
+	new := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+
+	diff, err := runetoken.FormatUnifiedDiff(runetoken.Diff(old, new), runetoken.Array(old), "a/file", "b/file", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `--- a/file
++++ b/file
+@@ -1,3 +1,3 @@
+ one
+-two
++2
+ three
+@@ -9,2 +9,2 @@
+ nine
+-ten
+\ No newline at end of file
++ten
+`
+	if diff != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, diff)
+	}
+}
+
+func TestParseUnifiedDiffOffsetAndFuzz(t *testing.T) {
+	diff := `diff --git a/config b/config
+index 3b18e51..a4c3a2b 100644
+--- a/config
++++ b/config
+@@ -2,5 +2,5 @@ section
+ alpha
+ beta
+-gamma
++GAMMA
+ delta
+ epsilon
+`
+	// the document has moved on since the diff was made: a line has been added at the top and the last context line
+	// has been changed
+	base := "header\nsection\nalpha\nbeta\ngamma\ndelta\nEPSILON\n"
+
+	op, err := runetoken.ParseUnifiedDiff(diff, runetoken.Array(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := runetoken.ApplyToString(op, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "header\nsection\nalpha\nbeta\nGAMMA\ndelta\nEPSILON\n"; applied != expected {
+		t.Errorf("expected %q, got %q", expected, applied)
+	}
+
+	_, err = runetoken.ParseUnifiedDiff(diff, runetoken.Array("alpha\nbeta\ndelta\n"))
+	if !errors.Is(err, runetoken.ErrHunkNotFound) {
+		t.Errorf("expected ErrHunkNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package gollab_test
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/runetoken"
+)
+
+func TestUTF16Offsets(t *testing.T) {
+	doc := runetoken.Array("a😀bé🇨🇿")
+
+	for idx := 0; idx <= len(doc); idx++ {
+		expected := len(utf16.Encode(doc[:idx]))
+		offset, err := runetoken.RuneToUTF16Offset(doc, idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset != expected {
+			t.Errorf("RuneToUTF16Offset(%d): expected %d, got %d", idx, expected, offset)
+		}
+
+		back, err := runetoken.UTF16ToRuneOffset(doc, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if back != idx {
+			t.Errorf("UTF16ToRuneOffset(%d): expected %d, got %d", offset, idx, back)
+		}
+
+		byteOffset, err := runetoken.RuneToByteOffset(doc, idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := len(string(doc[:idx])); byteOffset != expected {
+			t.Errorf("RuneToByteOffset(%d): expected %d, got %d", idx, expected, byteOffset)
+		}
+		if back, err := runetoken.ByteToRuneOffset(doc, byteOffset); err != nil || back != idx {
+			t.Errorf("ByteToRuneOffset(%d): expected %d, got %d (%v)", byteOffset, idx, back, err)
+		}
+	}
+
+	if doc.UTF16Len() != 9 {
+		t.Errorf("expected UTF-16 length 9, got %d", doc.UTF16Len())
+	}
+	if _, err := runetoken.UTF16ToRuneOffset(doc, 2); err != runetoken.ErrInvalidOffset {
+		t.Errorf("expected ErrInvalidOffset inside a surrogate pair, got %v", err)
+	}
+	if _, err := runetoken.ByteToRuneOffset(doc, 2); err != runetoken.ErrInvalidOffset {
+		t.Errorf("expected ErrInvalidOffset inside a UTF-8 sequence, got %v", err)
+	}
+	if _, err := runetoken.RuneToUTF16Offset(doc, len(doc)+1); err != runetoken.ErrInvalidOffset {
+		t.Errorf("expected ErrInvalidOffset past the end, got %v", err)
+	}
+}
+
+func TestOpFromUTF16(t *testing.T) {
+	doc := runetoken.Array("a😀b")
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 1},
+		gollab.Delete{Count: 2},
+		gollab.Insert{Tokens: runetoken.Array("😎")},
+		gollab.Retain{Count: 1},
+	)
+
+	converted, err := runetoken.OpFromUTF16(op, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := gollab.NewCompositeOp(
+		gollab.Retain{Count: 1},
+		gollab.Insert{Tokens: runetoken.Array("😎")},
+		gollab.Delete{Count: 1},
+		gollab.Retain{Count: 1},
+	)
+	if !reflect.DeepEqual(converted, expected) {
+		t.Errorf("expected %v, got %v", expected, converted)
+	}
+
+	if _, err := runetoken.OpFromUTF16(gollab.NewCompositeOp(gollab.Retain{Count: 2}, gollab.Delete{Count: 2}),
+		doc); err != runetoken.ErrInvalidOffset {
+		t.Errorf("expected ErrInvalidOffset, got %v", err)
+	}
+	if _, err := runetoken.OpFromUTF16(gollab.NewCompositeOp(gollab.Retain{Count: 3}), doc); err !=
+		gollab.ErrLengthMismatch {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
+
+func TestOpUTF16Attributes(t *testing.T) {
+	doc := runetoken.Array("a😀b")
+	bold := gollab.Attributes{"bold": true}
+	op := gollab.NewCompositeOp(
+		gollab.Retain{Count: 1},
+		gollab.Retain{Count: 2, Attributes: bold},
+		gollab.Insert{Tokens: runetoken.Array("!"), Attributes: bold},
+		gollab.Retain{Count: 1},
+	)
+
+	converted, err := runetoken.OpFromUTF16(op, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := gollab.NewCompositeOp(
+		gollab.Retain{Count: 1},
+		gollab.Retain{Count: 1, Attributes: bold},
+		gollab.Insert{Tokens: runetoken.Array("!"), Attributes: bold},
+		gollab.Retain{Count: 1},
+	)
+	if !reflect.DeepEqual(converted, expected) {
+		t.Errorf("expected %v, got %v", expected, converted)
+	}
+
+	utf16Op, err := runetoken.OpToUTF16(converted, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(utf16Op, op) {
+		t.Errorf("expected %v, got %v", op, utf16Op)
+	}
+}
+
+func TestOpUTF16RoundTrip(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+			base := make(runetoken.Array, op.InputLength())
+			for i := range base {
+				base[i] = []rune("a😀é🇨")[rand.Intn(4)]
+			}
+
+			utf16Op, err := runetoken.OpToUTF16(op, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if utf16Op.InputLength() != base.UTF16Len() {
+				t.Errorf("expected UTF-16 op input length %d, got %d", base.UTF16Len(), utf16Op.InputLength())
+			}
+
+			back, err := runetoken.OpFromUTF16(utf16Op, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, op) {
+				t.Errorf("expected %v, got %v", op, back)
+			}
+		})
+	}
+}
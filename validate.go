@@ -0,0 +1,97 @@
+package gollab
+
+import "reflect"
+
+// Validate checks whether the operation is a valid, normalized operation which can be applied to a document of length
+// docLen. This should be used on operations coming from untrusted sources, such as those decoded from JSON.
+//
+// An operation is valid if all its PrimitiveOps are valid, all its Insert operations carry tokens of the same
+// TokenArrayType, it is normalized (i.e. contains no NoOps and NewCompositeOp would leave it unchanged) and its input
+// length equals docLen. The returned error is either an *InvalidOpError or a *LengthMismatchError.
+func (c CompositeOp) Validate(docLen int) error {
+	var tokenType reflect.Type
+	for i, p := range c {
+		if err := validatePrimitive(p, &tokenType); err != nil {
+			return &InvalidOpError{Index: i, Op: p, Err: err}
+		}
+	}
+
+	for i := 0; i < len(c)-1; i++ {
+		if !isNormalized(c[i], c[i+1]) {
+			return &InvalidOpError{Index: i, Op: c[i], Err: ErrNotNormalized}
+		}
+	}
+
+	if l := c.InputLength(); l != docLen {
+		return &LengthMismatchError{
+			AIndex:  -1,
+			BIndex:  -1,
+			ALength: l,
+			BLength: docLen,
+		}
+	}
+	return nil
+}
+
+// ValidateApply checks whether the operation can be applied to a document of length docLen. Unlike Validate, it accepts
+// operations which aren't normalized, only requiring all its PrimitiveOps to be valid and its input length to equal
+// docLen. The returned error is either an *InvalidOpError or ErrLengthMismatch.
+//
+// TokenArrayEditor implementations should call it before applying an operation. ApplyTokens does so.
+func (c CompositeOp) ValidateApply(docLen int) error {
+	for i, p := range c {
+		var err error
+		switch p := p.(type) {
+		case nil:
+			err = ErrUnknownOp
+		case Validator:
+			err = p.Validate()
+		}
+		if err != nil {
+			return &InvalidOpError{Index: i, Op: p, Err: err}
+		}
+	}
+
+	if c.InputLength() != docLen {
+		return ErrLengthMismatch
+	}
+	return nil
+}
+
+func validatePrimitive(p PrimitiveOp, tokenType *reflect.Type) error {
+	switch p := p.(type) {
+	case nil:
+		return ErrUnknownOp
+	case NoOp:
+		return ErrNotNormalized
+	case Insert:
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		if t := reflect.TypeOf(p.Tokens.Type()); *tokenType == nil {
+			*tokenType = t
+		} else if t != *tokenType {
+			return ErrMixedTokenTypes
+		}
+		return nil
+	case Validator:
+		return p.Validate()
+	default:
+		return nil
+	}
+}
+
+// isNormalized returns false if two consecutive operations would be joined or swapped by normalization. Operations are
+// only ever joined with operations of the same type and attributes, which is checked directly to avoid joining Inserts'
+// tokens.
+func isNormalized(p, next PrimitiveOp) bool {
+	if reflect.TypeOf(p) == reflect.TypeOf(next) && attributesOf(p).Equal(attributesOf(next)) {
+		if _, ok := p.(joinable); ok {
+			return false
+		}
+	}
+	if swappable, ok := p.(swappable); ok && swappable.Swap(next) {
+		return false
+	}
+	return true
+}
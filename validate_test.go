@@ -0,0 +1,81 @@
+package gollab_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/danielslee/gollab/runetoken"
+	"math/rand"
+	"testing"
+
+	"github.com/danielslee/gollab"
+	"github.com/danielslee/gollab/server"
+)
+
+type otherArray struct {
+	runetoken.Array
+}
+
+type otherArrayType struct {
+	runetoken.ArrayType
+}
+
+func (otherArray) Type() gollab.TokenArrayType {
+	return otherArrayType{}
+}
+
+func TestValidate(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		t.Run(fmt.Sprintf("rand-%d", i), func(t *testing.T) {
+			t.Parallel()
+			l := rand.Intn(20) + 6
+			op := randomCompositeOp(l, l+rand.Intn(10)-5)
+			if err := op.Validate(l); err != nil {
+				t.Errorf("expected %v to be valid, got %v", op, err)
+			}
+		})
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	for _, test := range []struct {
+		op       gollab.CompositeOp
+		expected error
+		index    int
+	}{
+		{gollab.CompositeOp{gollab.Retain{Count: -5}, gollab.Retain{Count: 10}}, gollab.ErrInvalidCount, 0},
+		{gollab.CompositeOp{gollab.Retain{Count: 5}, gollab.Delete{Count: 0}}, gollab.ErrInvalidCount, 1},
+		{gollab.CompositeOp{gollab.Retain{Count: 5}, gollab.Insert{}}, gollab.ErrNilTokens, 1},
+		{gollab.CompositeOp{gollab.Insert{Tokens: runetoken.Array{}}, gollab.Retain{Count: 5}},
+			gollab.ErrInvalidCount, 0},
+		{gollab.CompositeOp{gollab.Insert{Tokens: runetoken.Array("a")}, gollab.Retain{Count: 5},
+			gollab.Insert{Tokens: otherArray{runetoken.Array("b")}}}, gollab.ErrMixedTokenTypes, 2},
+		{gollab.CompositeOp{gollab.Retain{Count: 2}, gollab.Retain{Count: 3}}, gollab.ErrNotNormalized, 0},
+		{gollab.CompositeOp{gollab.Retain{Count: 2}, gollab.Delete{Count: 3},
+			gollab.Insert{Tokens: runetoken.Array("a")}}, gollab.ErrNotNormalized, 1},
+		{gollab.CompositeOp{gollab.Retain{Count: 5}, gollab.NoOp{}}, gollab.ErrNotNormalized, 1},
+		{gollab.CompositeOp{gollab.Retain{Count: 5}, nil}, gollab.ErrUnknownOp, 1},
+	} {
+		err := test.op.Validate(5)
+		var invalidErr *gollab.InvalidOpError
+		if !errors.As(err, &invalidErr) || !errors.Is(err, test.expected) || invalidErr.Index != test.index {
+			t.Errorf("%v: expected %v at index %d, got %v", test.op, test.expected, test.index, err)
+		}
+	}
+}
+
+func TestValidateLength(t *testing.T) {
+	op := gollab.NewCompositeOp(gollab.Retain{Count: 4}, gollab.Insert{Tokens: runetoken.Array("a")})
+	if err := op.Validate(5); !errors.Is(err, gollab.ErrLengthMismatch) {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
+
+func TestApplyClientOpValidates(t *testing.T) {
+	_, err := server.ApplyClientOp(server.ApplyClientOpInput{
+		CurrentDocument: runetoken.Array("hello"),
+		Op:              gollab.CompositeOp{gollab.Retain{Count: 5}, gollab.Insert{}},
+	})
+	if !errors.Is(err, server.ErrInvalidOperation) {
+		t.Errorf("expected ErrInvalidOperation, got %v", err)
+	}
+}